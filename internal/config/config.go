@@ -0,0 +1,406 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Conflict policies applied when a request would join two established clusters
+const (
+	ConflictPolicyMerge = "merge"
+	ConflictPolicyFlag  = "flag"
+)
+
+// Matching modes deciding when a request joins an existing cluster
+const (
+	MatchModeOr    = "or"
+	MatchModeAnd   = "and"
+	MatchModeEmail = "email"
+)
+
+// Strategies choosing which contact of a cluster is the primary
+const (
+	PrimaryStrategyOldest   = "oldest"
+	PrimaryStrategyLowestID = "lowest-id"
+	PrimaryStrategyVerified = "verified"
+)
+
+// Handling of emails mixing Latin letters with lookalikes from other scripts
+const (
+	EmailUnicodeCollapse = "collapse"
+	EmailUnicodeFlag     = "flag"
+)
+
+// Handling of requests whose email and phone number look swapped
+const (
+	SwappedFieldsSwap   = "swap"
+	SwappedFieldsReject = "reject"
+)
+
+// PII handling modes for persisted audit requests
+const (
+	AuditPIIRedact = "redact"
+	AuditPIIRaw    = "raw"
+)
+
+// Config holds the runtime settings read from the environment
+type Config struct {
+	Port        string
+	DatabaseURL string
+	AdminToken  string
+	// APIToken, when set, is required as a bearer token on /identify and
+	// /bulk-identify
+	APIToken string
+
+	// ShutdownTimeout bounds draining in-flight requests and queued audit
+	// records after SIGTERM/SIGINT
+	ShutdownTimeout time.Duration
+
+	// Env is the deployment profile ("production", "dev" or "test")
+	Env string
+
+	// DBMaxOpenConns caps the pool and DBMaxIdleConns sizes its idle part;
+	// DBWarmUp pre-opens that many connections
+	DBMaxOpenConns int
+	DBMaxIdleConns int
+	DBWarmUp       bool
+
+	// DBConnMaxLifetime recycles connections older than this (0 keeps them)
+	DBConnMaxLifetime time.Duration
+
+	// DBConnMaxIdleTime closes connections idle for longer; DBKeepAliveInterval
+	// pings idle connections so the server does not drop them (0 disables either)
+	DBConnMaxIdleTime   time.Duration
+	DBKeepAliveInterval time.Duration
+
+	// DBTimeout bounds the database work of a single request (0 disables it)
+	DBTimeout time.Duration
+
+	// ConflictPolicy decides what happens when the email and phone of a request
+	// belong to two different established clusters ("merge" or "flag")
+	ConflictPolicy string
+
+	// ConflictResolverURL, when set, is POSTed every request that would merge
+	// existing clusters and answers merge, keep-separate or flag-for-review; after
+	// ConflictResolverTimeout or on errors ConflictResolverFallback applies
+	ConflictResolverURL      string
+	ConflictResolverTimeout  time.Duration
+	ConflictResolverFallback string
+
+	// MatchMode "or" links on a shared email OR phone; "and" only links a request
+	// carrying both when both are already known, otherwise it becomes a new primary;
+	// "email" links on emails only and lets a phone number, shared by a household,
+	// attach emailless requests to its oldest cluster without merging clusters
+	MatchMode string
+
+	// PrimaryStrategy picks the primary of a cluster: the earliest created contact
+	// ("oldest"), the smallest id ("lowest-id") or the earliest contact with a
+	// verified identifier ("verified"); requests may override it
+	PrimaryStrategy string
+
+	// EmptyIdentifierPlaceholder, when set, is returned as the only entry of an
+	// otherwise empty emails/phoneNumbers array (email-only or phone-only clusters)
+	EmptyIdentifierPlaceholder string
+
+	// CanonicalPromotion rewrites the primary row with the most frequently seen
+	// email and phone number of its cluster on every identify
+	CanonicalPromotion bool
+
+	// EmailCanonicalProviders lists email domains (e.g. gmail.com) whose plus tags
+	// and local-part dots are ignored when matching; stored emails are unchanged
+	EmailCanonicalProviders []string
+
+	// EmailNormalization and PhoneNormalization are the ordered normalization steps
+	// ("trim", "lowercase", "strip-plus", "strip-format", "e164", "nfkc") applied to each field
+	EmailNormalization []string
+	PhoneNormalization []string
+
+	// SwappedFieldsPolicy swaps ("swap") or rejects ("reject") requests whose email
+	// looks like a phone number or phone number looks like an email; off when empty
+	SwappedFieldsPolicy string
+
+	// EmailUnicodePolicy NFKC-normalizes emails when set and either collapses
+	// ("collapse") or rejects ("flag") addresses using homoglyphs of Latin letters
+	EmailUnicodePolicy string
+
+	// MatchCacheTTL caches identifier -> primary lookups of GET /primary and
+	// MatchCacheNegativeTTL caches identifiers known to be absent; zero disables
+	MatchCacheTTL         time.Duration
+	MatchCacheNegativeTTL time.Duration
+
+	// PartialResponses returns the primary and the members that could be read,
+	// flagged as partial, when loading the cluster for a response fails midway
+	PartialResponses bool
+
+	// LegacyPrimaryKey adds the misspelled primaryContatctId response key next
+	// to primaryContactId for integrations that still parse it (off by default);
+	// it will be removed in the next release
+	LegacyPrimaryKey bool
+
+	// EchoStatus repeats the HTTP status and a success flag in JSON response bodies
+	EchoStatus bool
+
+	// LogDemotions logs every primary demoted to secondary during a merge
+	LogDemotions bool
+
+	// SoftDelete stamps deleted_at on deleted contacts; false removes their rows
+	SoftDelete bool
+
+	// JoinVelocityLimit stops linking on an email or phone number that arrived with
+	// more distinct partner identifiers than this within JoinVelocityWindow,
+	// treating it as shared; 0 disables the check
+	JoinVelocityLimit  int
+	JoinVelocityWindow time.Duration
+
+	// ExactMatchFastPath skips reconciliation when the request equals a primary's stored values
+	ExactMatchFastPath bool
+
+	// VerifySinglePrimary re-checks after each identify that the request's contacts
+	// share one primary and otherwise restarts the transaction; IdentifyMaxRetries
+	// bounds the restarts, including those of transactions PostgreSQL aborted
+	VerifySinglePrimary bool
+	IdentifyMaxRetries  int
+
+	// IdentifyConcurrency caps concurrent identify requests (0 disables the queue);
+	// up to IdentifyQueueSize more wait in arrival order for IdentifyQueueTimeout
+	IdentifyConcurrency  int
+	IdentifyQueueSize    int
+	IdentifyQueueTimeout time.Duration
+
+	// RateLimitRPS is the sustained identify requests per second allowed per
+	// client IP, with bursts up to RateLimitBurst (0 disables the limit).
+	// RateLimitTrustProxy takes the client IP from X-Forwarded-For.
+	RateLimitRPS        float64
+	RateLimitBurst      int
+	RateLimitTrustProxy bool
+
+	// CORSOrigins lists the browser origins allowed to call /identify and
+	// /health ("*" allows any); empty disables CORS
+	CORSOrigins []string
+
+	// MaxBodyBytes caps identify request bodies; larger ones are rejected with 413
+	MaxBodyBytes int64
+
+	// LogFormat selects the access log layout ("text" or "json")
+	LogFormat string
+
+	// DecisionLog emits a hashed JSON event per identify to DecisionLogPath
+	// (stdout when empty), salting identifier hashes with DecisionLogSalt
+	DecisionLog     bool
+	DecisionLogPath string
+	DecisionLogSalt string
+
+	// AuditLog stores the raw identify body with its resulting primary for admins;
+	// AuditPII is "redact" (email/phone replaced) or "raw", and entries older than
+	// AuditRetentionDays are pruned
+	AuditLog           bool
+	AuditPII           string
+	AuditRetentionDays int
+
+	// Features toggles route groups (see featureGroups); groups missing from the
+	// map are enabled
+	Features map[string]bool
+}
+
+// Load reads the configuration from environment variables, applying defaults.
+// It fails on a FEATURE_FLAGS value that cannot be trusted, so a typo never
+// silently exposes a route group meant to be disabled, and on unknown values of
+// settings such as MATCH_MODE that only accept a fixed set.
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:                       getEnv("PORT", "8080"),
+		DatabaseURL:                getEnv("DATABASE_URL", "./bitespeed.db"),
+		AdminToken:                 os.Getenv("ADMIN_TOKEN"),
+		APIToken:                   os.Getenv("API_TOKEN"),
+		ShutdownTimeout:            getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+		Env:                        strings.ToLower(getEnv("APP_ENV", "production")),
+		DBMaxOpenConns:             getEnvInt("DB_MAX_OPEN", 25),
+		DBMaxIdleConns:             getEnvInt("DB_MAX_IDLE", getEnvInt("DB_MAX_IDLE_CONNS", 2)),
+		DBWarmUp:                   getEnvBool("DB_WARMUP", false),
+		DBConnMaxLifetime:          getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime:          getEnvDuration("DB_CONN_MAX_IDLE_TIME", 0),
+		DBKeepAliveInterval:        getEnvDuration("DB_KEEPALIVE_INTERVAL", 0),
+		DBTimeout:                  time.Duration(getEnvInt("DB_TIMEOUT_MS", 0)) * time.Millisecond,
+		ConflictPolicy:             strings.ToLower(getEnv("CONFLICT_POLICY", ConflictPolicyMerge)),
+		ConflictResolverURL:        os.Getenv("CONFLICT_RESOLVER_URL"),
+		ConflictResolverTimeout:    getEnvDuration("CONFLICT_RESOLVER_TIMEOUT", 2*time.Second),
+		ConflictResolverFallback:   strings.ToLower(getEnv("CONFLICT_RESOLVER_FALLBACK", "merge")),
+		MatchMode:                  strings.ToLower(getEnv("MATCH_MODE", MatchModeOr)),
+		PrimaryStrategy:            strings.ToLower(getEnv("PRIMARY_STRATEGY", PrimaryStrategyOldest)),
+		EmptyIdentifierPlaceholder: os.Getenv("EMPTY_IDENTIFIER_PLACEHOLDER"),
+		CanonicalPromotion:         getEnvBool("CANONICAL_PROMOTION", false),
+		EmailCanonicalProviders:    getEnvList("EMAIL_CANONICAL_PROVIDERS"),
+		EmailNormalization:         getEnvListOr("EMAIL_NORMALIZATION", []string{"trim", "lowercase"}),
+		PhoneNormalization:         getEnvListOr("PHONE_NORMALIZATION", []string{"trim", "strip-format"}),
+		SwappedFieldsPolicy:        strings.ToLower(os.Getenv("SWAPPED_FIELDS_POLICY")),
+		EmailUnicodePolicy:         strings.ToLower(os.Getenv("EMAIL_UNICODE_POLICY")),
+		MatchCacheTTL:              getEnvDuration("MATCH_CACHE_TTL", 0),
+		MatchCacheNegativeTTL:      getEnvDuration("MATCH_CACHE_NEGATIVE_TTL", 0),
+		PartialResponses:           getEnvBool("PARTIAL_RESPONSES", false),
+		LegacyPrimaryKey:           getEnvBool("LEGACY_PRIMARY_KEY", false),
+		EchoStatus:                 getEnvBool("ECHO_STATUS", false),
+		LogDemotions:               getEnvBool("LOG_DEMOTIONS", true),
+		SoftDelete:                 getEnvBool("SOFT_DELETE", true),
+		JoinVelocityLimit:          getEnvInt("JOIN_VELOCITY_LIMIT", 0),
+		JoinVelocityWindow:         getEnvDuration("JOIN_VELOCITY_WINDOW", time.Hour),
+		ExactMatchFastPath:         getEnvBool("EXACT_MATCH_FAST_PATH", false),
+		VerifySinglePrimary:        getEnvBool("VERIFY_SINGLE_PRIMARY", false),
+		IdentifyMaxRetries:         getEnvInt("IDENTIFY_MAX_RETRIES", 3),
+		IdentifyConcurrency:        getEnvInt("IDENTIFY_CONCURRENCY", 0),
+		IdentifyQueueSize:          getEnvInt("IDENTIFY_QUEUE_SIZE", 100),
+		IdentifyQueueTimeout:       getEnvDuration("IDENTIFY_QUEUE_TIMEOUT", 2*time.Second),
+		RateLimitRPS:               getEnvFloat("RATE_LIMIT_RPS", 0),
+		RateLimitBurst:             getEnvInt("RATE_LIMIT_BURST", 20),
+		RateLimitTrustProxy:        getEnvBool("RATE_LIMIT_TRUST_PROXY", false),
+		CORSOrigins:                getEnvList("CORS_ORIGINS"),
+		MaxBodyBytes:               int64(getEnvInt("MAX_BODY_BYTES", 1<<20)),
+		LogFormat:                  strings.ToLower(getEnv("LOG_FORMAT", "text")),
+		DecisionLog:                getEnvBool("DECISION_LOG", false),
+		DecisionLogPath:            os.Getenv("DECISION_LOG_PATH"),
+		DecisionLogSalt:            os.Getenv("DECISION_LOG_SALT"),
+		AuditLog:                   getEnvBool("AUDIT_LOG", false),
+		AuditPII:                   strings.ToLower(getEnv("AUDIT_PII", AuditPIIRedact)),
+		AuditRetentionDays:         getEnvInt("AUDIT_RETENTION_DAYS", 30),
+	}
+
+	err := checkEnums(
+		enumSetting{"MATCH_MODE", cfg.MatchMode, []string{MatchModeOr, MatchModeAnd, MatchModeEmail}},
+		enumSetting{"PRIMARY_STRATEGY", cfg.PrimaryStrategy, []string{PrimaryStrategyOldest, PrimaryStrategyLowestID, PrimaryStrategyVerified}},
+		enumSetting{"CONFLICT_POLICY", cfg.ConflictPolicy, []string{ConflictPolicyMerge, ConflictPolicyFlag}},
+		enumSetting{"SWAPPED_FIELDS_POLICY", cfg.SwappedFieldsPolicy, []string{SwappedFieldsSwap, SwappedFieldsReject}},
+		enumSetting{"EMAIL_UNICODE_POLICY", cfg.EmailUnicodePolicy, []string{EmailUnicodeCollapse, EmailUnicodeFlag}},
+		enumSetting{"AUDIT_PII", cfg.AuditPII, []string{AuditPIIRedact, AuditPIIRaw}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	features, err := getEnvFeatures("FEATURE_FLAGS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.Features = features
+	return cfg, nil
+}
+
+// enumSetting is a setting that only accepts a fixed set of values
+type enumSetting struct {
+	key     string
+	value   string
+	allowed []string
+}
+
+// checkEnums fails on the first setting holding a value it does not accept;
+// falling back to the default instead would silently change how contacts are
+// matched. An empty value leaves an optional setting off.
+func checkEnums(settings ...enumSetting) error {
+	for _, setting := range settings {
+		if setting.value != "" && !slices.Contains(setting.allowed, setting.value) {
+			return fmt.Errorf("invalid %s: unknown value %q (known: %s)", setting.key, setting.value, strings.Join(setting.allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// DevProfile reports whether development-only endpoints may be served
+func (c *Config) DevProfile() bool {
+	return c.Env == "dev" || c.Env == "test"
+}
+
+// FeatureEnabled reports whether a route group should be registered
+func (c *Config) FeatureEnabled(name string) bool {
+	enabled, ok := c.Features[name]
+	return !ok || enabled
+}
+
+// getEnv returns the environment variable or the fallback when unset
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvBool parses a boolean environment variable, returning the fallback when unset or invalid
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvInt parses an integer environment variable, returning the fallback when unset or invalid
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvFloat parses a number environment variable, returning the fallback when unset or invalid
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvDuration parses a duration such as "30s", returning the fallback when unset or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList parses a comma-separated environment variable into lowercase, non-empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvListOr is getEnvList with a fallback for unset or empty variables
+func getEnvListOr(key string, fallback []string) []string {
+	if values := getEnvList(key); len(values) > 0 {
+		return values
+	}
+	return fallback
+}
+
+// featureGroups are the route groups FEATURE_FLAGS may toggle
+var featureGroups = []string{"identify", "lookup", "contacts", "health", "admin", "metrics", "simulate"}
+
+// getEnvFeatures parses a JSON object of feature flags such as {"admin":false}.
+// Invalid JSON and unknown group names are errors rather than ignored, as
+// ignoring them would leave every group enabled.
+func getEnvFeatures(key string) (map[string]bool, error) {
+	features := make(map[string]bool)
+	raw := os.Getenv(key)
+	if raw == "" {
+		return features, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &features); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	for name := range features {
+		if !slices.Contains(featureGroups, name) {
+			return nil, fmt.Errorf("invalid %s: unknown route group %q (known: %s)", key, name, strings.Join(featureGroups, ", "))
+		}
+	}
+	return features, nil
+}
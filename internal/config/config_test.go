@@ -0,0 +1,67 @@
+package config
+
+import "testing"
+
+func TestLoadFeatureFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   string
+		wantErr bool
+		// enabled state of the admin group when loading succeeds
+		admin bool
+	}{
+		{name: "unset", flags: "", admin: true},
+		{name: "disabled group", flags: `{"admin":false}`, admin: false},
+		{name: "enabled group", flags: `{"admin":true,"metrics":false}`, admin: true},
+		{name: "invalid JSON", flags: `{"admin":false`, wantErr: true},
+		{name: "unknown group", flags: `{"admin":false,"admn":false}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FEATURE_FLAGS", tt.flags)
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := cfg.FeatureEnabled("admin"); got != tt.admin {
+				t.Errorf("FeatureEnabled(admin) = %v, want %v", got, tt.admin)
+			}
+		})
+	}
+}
+
+func TestLoadEnums(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{key: "MATCH_MODE", value: "and"},
+		{key: "MATCH_MODE", value: "EMAIL"},
+		{key: "MATCH_MODE", value: "both", wantErr: true},
+		{key: "PRIMARY_STRATEGY", value: "lowest-id"},
+		{key: "PRIMARY_STRATEGY", value: "newest", wantErr: true},
+		{key: "CONFLICT_POLICY", value: "flag"},
+		{key: "CONFLICT_POLICY", value: "review", wantErr: true},
+		{key: "SWAPPED_FIELDS_POLICY", value: "reject"},
+		{key: "SWAPPED_FIELDS_POLICY", value: "fix", wantErr: true},
+		{key: "EMAIL_UNICODE_POLICY", value: "collapse"},
+		{key: "EMAIL_UNICODE_POLICY", value: "reject", wantErr: true},
+		{key: "AUDIT_PII", value: "raw"},
+		{key: "AUDIT_PII", value: "hash", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			_, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,123 +1,373 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // DB wraps the sql.DB connection
 type DB struct {
 	Conn *sql.DB
+	// dialect is detected from the DSN once in New
+	dialect string
+
+	// stopKeepAlive ends the keepalive goroutine started by New, if any
+	stopKeepAlive chan struct{}
+}
+
+// Options tunes the connection pool created by New
+type Options struct {
+	// MaxOpenConns caps the open connections (0 keeps the driver default of no
+	// limit); SQLite always gets a single connection
+	MaxOpenConns int
+	// MaxIdleConns caps the idle connections kept in the pool (0 keeps the driver default)
+	MaxIdleConns int
+	// WarmUp opens and pings MaxIdleConns connections at startup (PostgreSQL only)
+	WarmUp bool
+	// ConnMaxIdleTime closes connections idle for longer (0 keeps them forever)
+	ConnMaxIdleTime time.Duration
+	// ConnMaxLifetime closes connections older than this (0 keeps them forever)
+	ConnMaxLifetime time.Duration
+	// KeepAliveInterval pings the idle connections this often so proxies such as
+	// Neon's do not silently drop them (0 disables, PostgreSQL only)
+	KeepAliveInterval time.Duration
 }
 
 // New creates a new database connection and runs migrations
-func New(dbPath string) (*DB, error) {
-	var conn *sql.DB
-	var err error
+func New(dbPath string, opts Options) (*DB, error) {
+	driver, err := detectDriver(dbPath)
+	if err != nil {
+		return nil, err
+	}
 
-	// Check if using PostgreSQL (Neon) or SQLite
-	if strings.HasPrefix(dbPath, "postgresql://") || strings.HasPrefix(dbPath, "postgres://") {
-		conn, err = sql.Open("postgres", dbPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open postgres database: %w", err)
-		}
-	} else {
-		conn, err = sql.Open("sqlite3", dbPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open sqlite database: %w", err)
-		}
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("database driver %q is not registered for DSN %q; rebuild with the %s driver imported", driver, redactDSN(dbPath), driver)
+	}
+
+	if driver == "sqlite3" {
+		dbPath = withSQLiteDefaults(dbPath)
+	}
+
+	conn, err := sql.Open(driver, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", driver, err)
 	}
 
+	configurePool(conn, driver, opts)
+
 	if err := conn.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db := &DB{Conn: conn}
+	db := &DB{Conn: conn, dialect: dialectOf(driver)}
+
+	// SQLite connections are local and cheap, only remote pools benefit from warming
+	if opts.WarmUp && driver == "postgres" && opts.MaxIdleConns > 0 {
+		if err := db.warmUp(opts.MaxIdleConns); err != nil {
+			return nil, fmt.Errorf("failed to warm up connection pool: %w", err)
+		}
+	}
 
-	if err := db.runMigrations(); err != nil {
+	applied, err := db.runMigrations()
+	if err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
+	log.Printf("Database schema at version %d (%d migrations applied)", migrations[len(migrations)-1].version, applied)
+
+	if opts.KeepAliveInterval > 0 && driver == "postgres" {
+		db.stopKeepAlive = make(chan struct{})
+		go db.keepAlive(opts.KeepAliveInterval)
+	}
 
 	log.Println("Database initialized successfully")
 	return db, nil
 }
 
+// configurePool applies the pool options. go-sqlite3 serializes writers poorly,
+// so SQLite gets a single connection instead of "database is locked" errors.
+func configurePool(conn *sql.DB, driver string, opts Options) {
+	if driver == "sqlite3" {
+		conn.SetMaxOpenConns(1)
+	} else if opts.MaxOpenConns > 0 {
+		conn.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		conn.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		conn.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		conn.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	}
+}
+
+// warmUp opens and pings n connections at once, then returns them to the pool as idle
+func (db *DB) warmUp(n int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := db.pingConns(ctx, n); err != nil {
+		return err
+	}
+
+	log.Printf("Warmed up %d database connections in %s", n, time.Since(start))
+	return nil
+}
+
+// keepAlive pings the idle connections every interval until Close. A connection
+// the server dropped fails its ping and is discarded by the pool, so the next
+// query gets a fresh one instead of failing.
+func (db *DB) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stopKeepAlive:
+			return
+		case <-ticker.C:
+		}
+
+		idle := db.Conn.Stats().Idle
+		if idle == 0 {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := db.pingConns(ctx, idle); err != nil {
+			log.Printf("Keepalive ping failed, connection replaced: %v", err)
+		}
+		cancel()
+	}
+}
+
+// pingConns checks out n connections at once, pings each and returns them to
+// the pool; the first failure is returned after every connection was released
+func (db *DB) pingConns(ctx context.Context, n int) error {
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	for range n {
+		c, err := db.Conn.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, c)
+
+		if err := c.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detectDriver validates the DSN and returns the sql driver name it requires.
+// Postgres URLs (Neon) use "postgres"; plain paths and file: URIs use "sqlite3".
+func detectDriver(dsn string) (string, error) {
+	if strings.TrimSpace(dsn) == "" {
+		return "", fmt.Errorf("database DSN is empty; set DATABASE_URL to a postgres:// URL or a SQLite file path")
+	}
+
+	scheme, _, hasScheme := strings.Cut(dsn, "://")
+	if !hasScheme {
+		// SQLite accepts plain paths, ":memory:" and "file:" URIs
+		return "sqlite3", nil
+	}
+
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// url.Error echoes the full DSN (including the password), so only keep the cause
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return "", fmt.Errorf("malformed postgres DSN: %w", err)
+		}
+		if u.Host == "" {
+			return "", fmt.Errorf("malformed postgres DSN %q: missing host", redactDSN(dsn))
+		}
+		return "postgres", nil
+	case "file":
+		return "sqlite3", nil
+	default:
+		return "", fmt.Errorf("unsupported database scheme %q; use postgres://, postgresql:// or a SQLite file path", scheme)
+	}
+}
+
+// sqliteDefaults are the DSN parameters New adds to SQLite paths that do not
+// set them. _txlock=immediate makes transactions take the write lock when they
+// begin: deferred transactions that read and then write can fail with
+// SQLITE_BUSY when two of them try to upgrade their locks at the same time.
+// WAL lets readers proceed during a write, and the busy timeout makes a writer
+// wait for the lock instead of failing with "database is locked".
+var sqliteDefaults = []struct{ key, value string }{
+	{"_txlock", "immediate"},
+	{"_journal_mode", "WAL"},
+	{"_busy_timeout", "5000"},
+}
+
+// withSQLiteDefaults appends the sqliteDefaults the DSN does not already set
+func withSQLiteDefaults(dsn string) string {
+	for _, param := range sqliteDefaults {
+		if strings.Contains(dsn, param.key+"=") {
+			continue
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + param.key + "=" + param.value
+	}
+	return dsn
+}
+
+// redactDSN strips the password from a DSN so it can be safely logged
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil {
+		return dsn
+	}
+	return u.Redacted()
+}
+
+// SQL dialects reported by Dialect
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// dialectOf maps a sql driver name to its dialect
+func dialectOf(driver string) string {
+	if driver == "postgres" {
+		return DialectPostgres
+	}
+	return DialectSQLite
+}
+
+// Dialect returns the SQL dialect of the database, detected from the DSN
+func (db *DB) Dialect() string {
+	return db.dialect
+}
+
 // isPostgres checks if using PostgreSQL
 func (db *DB) isPostgres() bool {
-	var version string
-	err := db.Conn.QueryRow("SELECT version()").Scan(&version)
-	if err != nil {
+	return db.dialect == DialectPostgres
+}
+
+// SerializableTx returns the options for transactions that must not interleave
+// with concurrent ones, such as two identify calls both creating a primary for
+// the same new email. PostgreSQL runs them SERIALIZABLE and aborts the loser
+// (see IsRetryable); SQLite already serializes writers through _txlock=immediate.
+func (db *DB) SerializableTx() *sql.TxOptions {
+	if db.isPostgres() {
+		return &sql.TxOptions{Isolation: sql.LevelSerializable}
+	}
+	return nil
+}
+
+// IsRetryable reports whether err aborted a transaction because of a concurrent
+// one (a PostgreSQL serialization failure or deadlock), so running it again
+// from the start can succeed
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
 		return false
 	}
-	return strings.Contains(strings.ToLower(version), "postgres")
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
 }
 
-// runMigrations executes the migration SQL files
-func (db *DB) runMigrations() error {
+// placeholderPattern matches the $N placeholders queries are written with
+var placeholderPattern = regexp.MustCompile(`\$\d+`)
+
+// Rebind rewrites the $N placeholders of a query for the driver: Postgres keeps
+// them, SQLite and MySQL get "?". Queries must use $1, $2, ... in argument
+// order, each once, so the positional "?" bind the same arguments.
+func (db *DB) Rebind(query string) string {
 	if db.isPostgres() {
-		return db.runPostgresMigration()
-	}
-	return db.runSQLiteMigration()
-}
-
-// runPostgresMigration runs PostgreSQL schema
-func (db *DB) runPostgresMigration() error {
-	schema := `
-CREATE TABLE IF NOT EXISTS contacts (
-    id SERIAL PRIMARY KEY,
-    phone_number TEXT,
-    email TEXT,
-    linked_id INTEGER,
-    link_precedence TEXT CHECK(link_precedence IN ('primary', 'secondary')),
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMP,
-    FOREIGN KEY (linked_id) REFERENCES contacts(id)
-);
-
-CREATE INDEX IF NOT EXISTS idx_phone ON contacts(phone_number);
-CREATE INDEX IF NOT EXISTS idx_email ON contacts(email);
-CREATE INDEX IF NOT EXISTS idx_linked_id ON contacts(linked_id);
-`
-	_, err := db.Conn.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("failed to execute postgres schema: %w", err)
+		return query
 	}
-	return nil
+	return placeholderPattern.ReplaceAllString(query, "?")
 }
 
-// runSQLiteMigration runs SQLite schema
-func (db *DB) runSQLiteMigration() error {
-	schema := `
-CREATE TABLE IF NOT EXISTS contacts (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    phone_number TEXT,
-    email TEXT,
-    linked_id INTEGER,
-    link_precedence TEXT CHECK(link_precedence IN ('primary', 'secondary')),
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    deleted_at DATETIME,
-    FOREIGN KEY (linked_id) REFERENCES contacts(id)
-);
-
-CREATE INDEX IF NOT EXISTS idx_phone ON contacts(phone_number);
-CREATE INDEX IF NOT EXISTS idx_email ON contacts(email);
-CREATE INDEX IF NOT EXISTS idx_linked_id ON contacts(linked_id);
-`
-	_, err := db.Conn.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("failed to execute sqlite schema: %w", err)
+// MaintenanceStep records the timing of a single maintenance statement
+type MaintenanceStep struct {
+	Statement  string  `json:"statement"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// MaintenanceResult summarizes a maintenance run
+type MaintenanceResult struct {
+	Dialect         string            `json:"dialect"`
+	Steps           []MaintenanceStep `json:"steps"`
+	TotalDurationMs float64           `json:"totalDurationMs"`
+}
+
+// RunMaintenance reclaims space and refreshes planner statistics for the contacts table
+func (db *DB) RunMaintenance() (*MaintenanceResult, error) {
+	result := &MaintenanceResult{Dialect: db.Dialect()}
+	statements := []string{"VACUUM", "ANALYZE contacts"}
+	if db.isPostgres() {
+		statements = []string{"VACUUM ANALYZE contacts", "REINDEX TABLE contacts"}
+	}
+
+	start := time.Now()
+	for _, stmt := range statements {
+		stepStart := time.Now()
+		if _, err := db.Conn.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("failed to run %q: %w", stmt, err)
+		}
+		result.Steps = append(result.Steps, MaintenanceStep{
+			Statement:  stmt,
+			DurationMs: durationMs(time.Since(stepStart)),
+		})
+	}
+	result.TotalDurationMs = durationMs(time.Since(start))
+
+	log.Printf("Database maintenance completed in %.2fms", result.TotalDurationMs)
+	return result, nil
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Ready checks that the database answers and that the migrations have created
+// the contacts table
+func (db *DB) Ready(ctx context.Context) error {
+	if err := db.Conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	var one int
+	err := db.Conn.QueryRowContext(ctx, "SELECT 1 FROM contacts LIMIT 1").Scan(&one)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("contacts table is not available: %w", err)
 	}
 	return nil
 }
 
 // Close closes the database connection
 func (db *DB) Close() error {
+	if db.stopKeepAlive != nil {
+		close(db.stopKeepAlive)
+	}
 	return db.Conn.Close()
 }
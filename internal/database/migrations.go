@@ -0,0 +1,335 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration is one schema change. Versions mirror the files in migrations/
+// and are applied in order, each exactly once; the dialects differ only in
+// their id and timestamp types.
+type migration struct {
+	version  int
+	name     string
+	postgres string
+	sqlite   string
+}
+
+// migrations lists every schema change. Append new steps with the next
+// version; never edit or reorder a step that has been released.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create contacts table",
+		postgres: `
+CREATE TABLE IF NOT EXISTS contacts (
+    id SERIAL PRIMARY KEY,
+    phone_number TEXT,
+    email TEXT,
+    linked_id INTEGER,
+    link_precedence TEXT CHECK(link_precedence IN ('primary', 'secondary')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    FOREIGN KEY (linked_id) REFERENCES contacts(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone ON contacts(phone_number);
+CREATE INDEX IF NOT EXISTS idx_email ON contacts(email);
+CREATE INDEX IF NOT EXISTS idx_linked_id ON contacts(linked_id);
+`,
+		sqlite: `
+CREATE TABLE IF NOT EXISTS contacts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    phone_number TEXT,
+    email TEXT,
+    linked_id INTEGER,
+    link_precedence TEXT CHECK(link_precedence IN ('primary', 'secondary')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    deleted_at DATETIME,
+    FOREIGN KEY (linked_id) REFERENCES contacts(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone ON contacts(phone_number);
+CREATE INDEX IF NOT EXISTS idx_email ON contacts(email);
+CREATE INDEX IF NOT EXISTS idx_linked_id ON contacts(linked_id);
+`,
+	},
+	{
+		version: 2,
+		name:    "create review_queue table",
+		postgres: `
+CREATE TABLE IF NOT EXISTS review_queue (
+    id SERIAL PRIMARY KEY,
+    email TEXT,
+    phone_number TEXT,
+    email_primary_id INTEGER,
+    phone_primary_id INTEGER,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'resolved')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_queue_status ON review_queue(status);
+`,
+		sqlite: `
+CREATE TABLE IF NOT EXISTS review_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT,
+    phone_number TEXT,
+    email_primary_id INTEGER,
+    phone_primary_id INTEGER,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'resolved')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_queue_status ON review_queue(status);
+`,
+	},
+	{
+		version: 3,
+		name:    "create merged_into table",
+		postgres: `
+CREATE TABLE IF NOT EXISTS merged_into (
+    id SERIAL PRIMARY KEY,
+    old_primary_id INTEGER NOT NULL,
+    new_primary_id INTEGER NOT NULL,
+    merged_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_merged_into_new ON merged_into(new_primary_id);
+CREATE INDEX IF NOT EXISTS idx_merged_into_old ON merged_into(old_primary_id);
+`,
+		sqlite: `
+CREATE TABLE IF NOT EXISTS merged_into (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    old_primary_id INTEGER NOT NULL,
+    new_primary_id INTEGER NOT NULL,
+    merged_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_merged_into_new ON merged_into(new_primary_id);
+CREATE INDEX IF NOT EXISTS idx_merged_into_old ON merged_into(old_primary_id);
+`,
+	},
+	{
+		version: 4,
+		name:    "add contacts.account_id",
+		postgres: `
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS account_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_account_id ON contacts(account_id);
+`,
+		// SQLite has no ADD COLUMN IF NOT EXISTS, see adoptUnversionedSQLite
+		sqlite: `
+ALTER TABLE contacts ADD COLUMN account_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_account_id ON contacts(account_id);
+`,
+	},
+	{
+		version: 5,
+		name:    "create email_aliases table",
+		postgres: `
+CREATE TABLE IF NOT EXISTS email_aliases (
+    alias TEXT PRIMARY KEY,
+    canonical TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+`,
+		sqlite: `
+CREATE TABLE IF NOT EXISTS email_aliases (
+    alias TEXT PRIMARY KEY,
+    canonical TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`,
+	},
+	{
+		version: 6,
+		name:    "create audit_log table",
+		postgres: `
+CREATE TABLE IF NOT EXISTS audit_log (
+    id SERIAL PRIMARY KEY,
+    raw_request TEXT NOT NULL,
+    primary_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+`,
+		sqlite: `
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    raw_request TEXT NOT NULL,
+    primary_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+`,
+	},
+	{
+		version: 7,
+		name:    "create verified_identifiers table",
+		postgres: `
+CREATE TABLE IF NOT EXISTS verified_identifiers (
+    kind TEXT NOT NULL CHECK(kind IN ('email', 'phone')),
+    value TEXT NOT NULL,
+    account_id TEXT NOT NULL DEFAULT '',
+    verified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (kind, value, account_id)
+);
+`,
+		sqlite: `
+CREATE TABLE IF NOT EXISTS verified_identifiers (
+    kind TEXT NOT NULL CHECK(kind IN ('email', 'phone')),
+    value TEXT NOT NULL,
+    account_id TEXT NOT NULL DEFAULT '',
+    verified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (kind, value, account_id)
+);
+`,
+	},
+	{
+		version: 8,
+		name:    "add contacts.email_match_key",
+		postgres: `
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS email_match_key TEXT;
+CREATE INDEX IF NOT EXISTS idx_email_match_key ON contacts(email_match_key);
+`,
+		sqlite: `
+ALTER TABLE contacts ADD COLUMN email_match_key TEXT;
+CREATE INDEX IF NOT EXISTS idx_email_match_key ON contacts(email_match_key);
+`,
+	},
+}
+
+// accountIDMigration is the version that adds contacts.account_id
+const accountIDMigration = 4
+
+// runMigrations applies the migrations not yet recorded in schema_migrations,
+// each in its own transaction, and returns how many it applied
+func (db *DB) runMigrations() (int, error) {
+	createTable := `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`
+	if _, err := db.Conn.Exec(createTable); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	if !db.isPostgres() {
+		if err := db.adoptUnversionedSQLite(); err != nil {
+			return 0, err
+		}
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return count, err
+		}
+		log.Printf("Applied migration %03d (%s)", m.version, m.name)
+		count++
+	}
+	return count, nil
+}
+
+// appliedMigrations returns the versions recorded in schema_migrations
+func (db *DB) appliedMigrations() (map[int]bool, error) {
+	rows, err := db.Conn.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration runs one migration and records it in the same transaction
+func (db *DB) applyMigration(m migration) error {
+	up := m.sqlite
+	if db.isPostgres() {
+		up = m.postgres
+	}
+
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %03d: %w", m.version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(up); err != nil {
+		return fmt.Errorf("failed to apply migration %03d (%s): %w", m.version, m.name, err)
+	}
+	if err := recordMigration(tx, db, m); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %03d: %w", m.version, err)
+	}
+	return nil
+}
+
+// recordMigration marks a migration as applied
+func recordMigration(tx *sql.Tx, db *DB, m migration) error {
+	insert := db.Rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`)
+	if _, err := tx.Exec(insert, m.version, m.name, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record migration %03d: %w", m.version, err)
+	}
+	return nil
+}
+
+// adoptUnversionedSQLite prepares SQLite databases created before migrations
+// were tracked. Every step re-runs harmlessly on them except 004: databases
+// created by a release that already shipped it have the account_id column, so
+// the step is recorded as applied instead of adding the column twice.
+func (db *DB) adoptUnversionedSQLite() error {
+	var recorded int
+	if err := db.Conn.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil {
+		return fmt.Errorf("failed to count applied migrations: %w", err)
+	}
+	if recorded > 0 {
+		return nil
+	}
+
+	var hasColumn int
+	query := `SELECT COUNT(*) FROM pragma_table_info('contacts') WHERE name = 'account_id'`
+	if err := db.Conn.QueryRow(query).Scan(&hasColumn); err != nil {
+		return fmt.Errorf("failed to inspect contacts columns: %w", err)
+	}
+	if hasColumn == 0 {
+		return nil
+	}
+
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin adopting unversioned schema: %w", err)
+	}
+	defer tx.Rollback()
+	if err := recordMigration(tx, db, migrations[accountIDMigration-1]); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB opens a fresh SQLite database file, running the migrations once
+func openTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+	db, err := New(path, Options{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// appliedVersions returns the versions recorded in schema_migrations
+func appliedVersions(t *testing.T, db *DB) map[int]bool {
+	t.Helper()
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		t.Fatalf("failed to list applied migrations: %v", err)
+	}
+	return applied
+}
+
+func TestRunMigrationsTwice(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "contacts.db"))
+
+	applied, err := db.runMigrations()
+	if err != nil {
+		t.Fatalf("second runMigrations failed: %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("second runMigrations applied %d migrations, want 0", applied)
+	}
+	if versions := appliedVersions(t, db); len(versions) != len(migrations) {
+		t.Errorf("%d migrations recorded, want %d", len(versions), len(migrations))
+	}
+}
+
+func TestRunMigrationsAdoptsUnversionedSQLite(t *testing.T) {
+	tests := []struct {
+		name string
+		// schema of the database before migrations were tracked
+		schema string
+		// whether 004 is recorded without running
+		adopted bool
+	}{
+		{
+			name:    "with account_id",
+			schema:  `CREATE TABLE contacts (id INTEGER PRIMARY KEY AUTOINCREMENT, phone_number TEXT, email TEXT, account_id TEXT, linked_id INTEGER, link_precedence TEXT, created_at DATETIME, updated_at DATETIME, deleted_at DATETIME)`,
+			adopted: true,
+		},
+		{
+			name:   "without account_id",
+			schema: `CREATE TABLE contacts (id INTEGER PRIMARY KEY AUTOINCREMENT, phone_number TEXT, email TEXT, linked_id INTEGER, link_precedence TEXT, created_at DATETIME, updated_at DATETIME, deleted_at DATETIME)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := sql.Open("sqlite3", withSQLiteDefaults(filepath.Join(t.TempDir(), "contacts.db")))
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			db := &DB{Conn: conn, dialect: dialectOf("sqlite3")}
+			t.Cleanup(func() { db.Close() })
+			if _, err := db.Conn.Exec(tt.schema); err != nil {
+				t.Fatalf("failed to create unversioned schema: %v", err)
+			}
+
+			applied, err := db.runMigrations()
+			if err != nil {
+				t.Fatalf("runMigrations failed: %v", err)
+			}
+			want := len(migrations)
+			if tt.adopted {
+				want--
+			}
+			if applied != want {
+				t.Errorf("runMigrations applied %d migrations, want %d", applied, want)
+			}
+			if versions := appliedVersions(t, db); len(versions) != len(migrations) {
+				t.Errorf("%d migrations recorded, want %d", len(versions), len(migrations))
+			}
+
+			var columns int
+			query := `SELECT COUNT(*) FROM pragma_table_info('contacts') WHERE name = 'account_id'`
+			if err := db.Conn.QueryRow(query).Scan(&columns); err != nil {
+				t.Fatalf("failed to inspect contacts: %v", err)
+			}
+			if columns != 1 {
+				t.Errorf("contacts has %d account_id columns, want 1", columns)
+			}
+
+			if applied, err := db.runMigrations(); err != nil || applied != 0 {
+				t.Errorf("second runMigrations = %d, %v, want 0, nil", applied, err)
+			}
+		})
+	}
+}
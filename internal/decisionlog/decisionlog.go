@@ -0,0 +1,83 @@
+// Package decisionlog emits one structured, PII-free event per reconciliation
+// decision, intended as training data for deduplication models.
+//
+// Events are written as JSON lines with the following stable schema (version 1):
+//
+//	schemaVersion  int     always 1 for this layout
+//	timestamp      string  RFC 3339 time of the decision (UTC)
+//	emailHash      string  hex SHA-256 of salt+email, empty when no email was sent
+//	phoneHash      string  hex SHA-256 of salt+phone number, empty when no phone was sent
+//	accountHash    string  hex SHA-256 of salt+accountId, empty when unscoped
+//	clusterSize    int     active contacts in the resulting cluster
+//	action         string  created_primary | created_secondary | merged | no_change
+//	confidence     float   share of the request identifiers already known to the cluster (0-1)
+//
+// New fields may be added; existing fields are never renamed or removed
+// without bumping schemaVersion.
+package decisionlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// SchemaVersion is the version of the Event layout
+const SchemaVersion = 1
+
+// Event is a single reconciliation decision
+type Event struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Timestamp     time.Time `json:"timestamp"`
+	EmailHash     string    `json:"emailHash"`
+	PhoneHash     string    `json:"phoneHash"`
+	AccountHash   string    `json:"accountHash"`
+	ClusterSize   int       `json:"clusterSize"`
+	Action        string    `json:"action"`
+	Confidence    float64   `json:"confidence"`
+}
+
+// Logger writes decision events as JSON lines to a sink
+type Logger struct {
+	mu   sync.Mutex
+	out  io.Writer
+	salt string
+}
+
+// New creates a logger writing to out, hashing identifiers with the given salt
+func New(out io.Writer, salt string) *Logger {
+	return &Logger{out: out, salt: salt}
+}
+
+// Hash returns the salted SHA-256 of an identifier, or "" for a missing one
+func (l *Logger) Hash(value *string) string {
+	if value == nil || *value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(l.salt + *value))
+	return hex.EncodeToString(sum[:])
+}
+
+// Log writes the event, filling in the schema version and timestamp
+func (l *Logger) Log(event Event) {
+	event.SchemaVersion = SchemaVersion
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding decision event: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.out.Write(append(line, '\n')); err != nil {
+		log.Printf("Error writing decision event: %v", err)
+	}
+}
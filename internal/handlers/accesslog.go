@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"bitespeed/internal/requestid"
+)
+
+// Access log formats accepted by AccessLog
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// ValidLogFormat reports whether format is known
+func ValidLogFormat(format string) bool {
+	return format == LogFormatText || format == LogFormatJSON
+}
+
+// accessEntry is one JSON access log line
+type accessEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	LatencyMS float64   `json:"latencyMs"`
+}
+
+// jsonAccessLog writes bare JSON lines, without the standard logger's prefix
+var jsonAccessLog = log.New(os.Stderr, "", 0)
+
+// AccessLog logs the method, path, status, latency and request id of every
+// request, as text or as JSON lines; the id is set by requestid.Middleware
+func AccessLog(format string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			entry := accessEntry{
+				Time:      start.UTC(),
+				RequestID: requestid.FromContext(r.Context()),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Status:    rec.status,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if format == LogFormatJSON {
+				line, err := json.Marshal(entry)
+				if err != nil {
+					log.Printf("Error encoding access log entry: %v", err)
+					return
+				}
+				jsonAccessLog.Println(string(line))
+				return
+			}
+			log.Printf("%s %s %d %.3fms request_id=%s", entry.Method, entry.Path, entry.Status, entry.LatencyMS, entry.RequestID)
+		})
+	}
+}
+
+// statusWriter remembers the status written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
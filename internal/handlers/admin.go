@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"bitespeed/internal/database"
+	"bitespeed/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminHandler handles operator-only endpoints under /admin
+type AdminHandler struct {
+	db      *database.DB
+	service *service.ReconciliationService
+	token   string
+}
+
+// NewAdminHandler creates a new admin handler guarded by the given bearer token.
+// An empty token disables every admin endpoint.
+func NewAdminHandler(db *database.DB, svc *service.ReconciliationService, token string) *AdminHandler {
+	return &AdminHandler{db: db, service: svc, token: token}
+}
+
+// RequireAdmin rejects requests that don't carry the admin bearer token
+func (h *AdminHandler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+
+		if !hasBearerToken(r, h.token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// Maintenance runs VACUUM/ANALYZE style maintenance on the contacts table
+func (h *AdminHandler) Maintenance(w http.ResponseWriter, r *http.Request) {
+	result, err := h.db.RunMaintenance()
+	if err != nil {
+		log.Printf("Error running maintenance: %v", err)
+		http.Error(w, "Maintenance failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// ReviewQueue lists identify requests flagged for manual review
+func (h *AdminHandler) ReviewQueue(w http.ResponseWriter, r *http.Request) {
+	items, err := h.service.ListPendingReviews(r.Context())
+	if err != nil {
+		log.Printf("Error listing review queue: %v", err)
+		http.Error(w, "Failed to list review queue", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"reviews": items}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// Stats reports contact counts and the largest cluster size
+func (h *AdminHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.service.Stats(r.Context())
+	if err != nil {
+		log.Printf("Error computing contact stats: %v", err)
+		http.Error(w, "Failed to compute stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// ListAliases returns the configured email alias mappings
+func (h *AdminHandler) ListAliases(w http.ResponseWriter, r *http.Request) {
+	aliases, err := h.service.ListEmailAliases(r.Context())
+	if err != nil {
+		log.Printf("Error listing email aliases: %v", err)
+		http.Error(w, "Failed to list aliases", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"aliases": aliases}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// SetAlias creates or updates an alias from a {"alias","canonical"} body
+func (h *AdminHandler) SetAlias(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Alias     string `json:"alias"`
+		Canonical string `json:"canonical"`
+	}
+	if err := decodeJSON(r.Body, &body); err != nil {
+		http.Error(w, decodeErrorMessage(err), http.StatusBadRequest)
+		return
+	}
+
+	err := h.service.SetEmailAlias(r.Context(), body.Alias, body.Canonical)
+	if errors.Is(err, service.ErrInvalidAlias) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving email alias: %v", err)
+		http.Error(w, "Failed to save alias", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteAlias removes the alias named in the route
+func (h *AdminHandler) DeleteAlias(w http.ResponseWriter, r *http.Request) {
+	found, err := h.service.DeleteEmailAlias(r.Context(), mux.Vars(r)["alias"])
+	if err != nil {
+		log.Printf("Error deleting email alias: %v", err)
+		http.Error(w, "Failed to delete alias", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Alias not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AuditLog returns the most recent persisted identify requests (?limit=, default 100)
+func (h *AdminHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	entries, err := h.service.ListAuditEntries(r.Context(), limit)
+	if err != nil {
+		log.Printf("Error listing audit log: %v", err)
+		http.Error(w, "Failed to list audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"entries": entries}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// DeleteContacts soft-deletes a list of contacts from a {"ids": [...]} body and
+// reports the outcome of each id
+func (h *AdminHandler) DeleteContacts(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := decodeJSON(r.Body, &body); err != nil {
+		http.Error(w, decodeErrorMessage(err), http.StatusBadRequest)
+		return
+	}
+	if len(body.IDs) == 0 {
+		http.Error(w, "ids must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.service.DeleteContacts(r.Context(), body.IDs)
+	if errors.Is(err, service.ErrTooManyIDs) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting contacts: %v", err)
+		http.Error(w, "Failed to delete contacts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"results": results}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// DeleteContact soft-deletes a single contact, promoting a successor when it
+// is a primary
+func (h *AdminHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseContactID(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.DeleteContact(r.Context(), id)
+	if errors.Is(err, service.ErrContactNotFound) {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	var conflictErr *service.ConflictError
+	if errors.As(err, &conflictErr) {
+		writeConflict(w, r, conflictErr)
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting contact %d: %v", id, err)
+		http.Error(w, "Failed to delete contact", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// RestoreContact undeletes a contact and returns the identity it rejoined
+func (h *AdminHandler) RestoreContact(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseContactID(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := h.service.Restore(r.Context(), id)
+	if errors.Is(err, service.ErrContactNotFound) {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrContactNotDeleted) {
+		http.Error(w, "Contact is not deleted", http.StatusConflict)
+		return
+	}
+	var conflictErr *service.ConflictError
+	if errors.As(err, &conflictErr) {
+		writeConflict(w, r, conflictErr)
+		return
+	}
+	if err != nil {
+		log.Printf("Error restoring contact %d: %v", id, err)
+		http.Error(w, "Failed to restore contact", http.StatusInternalServerError)
+		return
+	}
+
+	writeIdentifyResponse(w, r, response)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// RequireToken rejects requests that don't carry token as a bearer token with a
+// 401 JSON error. An empty token leaves the routes open, as for local
+// development.
+func RequireToken(token string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if token == "" {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !hasBearerToken(r, token) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "A valid bearer token is required")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// hasBearerToken compares the request's bearer token with token in constant time
+func hasBearerToken(r *http.Request, token string) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
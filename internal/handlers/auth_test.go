@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		status        int
+	}{
+		{name: "correct token", token: "s3cret", authorization: "Bearer s3cret", status: http.StatusOK},
+		{name: "missing token", token: "s3cret", status: http.StatusUnauthorized},
+		{name: "wrong token", token: "s3cret", authorization: "Bearer guess", status: http.StatusUnauthorized},
+		{name: "token prefix", token: "s3cret", authorization: "Bearer s3cre", status: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "s3cret", authorization: "Basic s3cret", status: http.StatusUnauthorized},
+		{name: "open without a token", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequireToken(tt.token)(func(w http.ResponseWriter, r *http.Request) {})
+
+			req := httptest.NewRequest(http.MethodPost, "/identify", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusUnauthorized {
+				if code := errorCode(t, rec); code != codeUnauthorized {
+					t.Errorf("error code = %q, want %q", code, codeUnauthorized)
+				}
+				if rec.Header().Get("WWW-Authenticate") == "" {
+					t.Error("401 without a WWW-Authenticate header")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"bitespeed/internal/metrics"
+	"bitespeed/internal/models"
+	"bitespeed/internal/openapi"
+	"bitespeed/internal/requestid"
+	"bitespeed/internal/service"
+)
+
+// MaxBulkIdentify caps the requests accepted by one /bulk-identify call
+const MaxBulkIdentify = 1000
+
+// BulkHandle reconciles a JSON array of identify requests, each in its own
+// transaction, and answers an array of results in the same order. A failing
+// element yields {"error": {...}} at its position and does not stop the rest,
+// including one the /identify schema rejects (the whole array is not checked
+// up front, so one bad element cannot fail the batch). Once the request is
+// canceled, the elements not yet started are reported as CANCELED; the ones
+// before them stay committed.
+func (h *IdentifyHandler) BulkHandle(w http.ResponseWriter, r *http.Request) {
+	var elements []json.RawMessage
+	err := decodeJSON(http.MaxBytesReader(w, r.Body, h.maxBodyBytes), &elements)
+	if writeBodyTooLarge(w, r, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+	if len(elements) > MaxBulkIdentify {
+		writeError(w, r, http.StatusRequestEntityTooLarge, codeBatchTooLarge, fmt.Sprintf("At most %d requests may be identified at once", MaxBulkIdentify))
+		return
+	}
+
+	opts, ok := identifyOptions(w, r)
+	if !ok {
+		return
+	}
+
+	results := make([]models.BulkIdentifyResult, 0, len(elements))
+	for i, raw := range elements {
+		if r.Context().Err() != nil {
+			requestid.Logf(r.Context(), "Bulk identify canceled after %d of %d elements", i, len(elements))
+			failure := &models.ErrorDetail{Code: codeCanceled, Message: fmt.Sprintf("Not processed: the request was canceled after %d of %d elements", i, len(elements))}
+			for range elements[i:] {
+				results = append(results, models.BulkIdentifyResult{Error: failure})
+			}
+			break
+		}
+		response, failure := h.identifyElement(r.Context(), raw, opts)
+		results = append(results, models.BulkIdentifyResult{IdentifyResponse: response, Error: failure})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		requestid.Logf(r.Context(), "Error encoding response: %v", err)
+	}
+}
+
+// identifyElement runs one element of a bulk request, returning either its
+// response or the error to report in its place
+func (h *IdentifyHandler) identifyElement(ctx context.Context, raw json.RawMessage, opts service.IdentifyOptions) (*models.IdentifyResponse, *models.ErrorDetail) {
+	if h.elementSchema != nil {
+		var invalid *openapi.ValidationError
+		if err := h.elementSchema.ValidateBody("application/json", raw); errors.As(err, &invalid) {
+			return nil, &models.ErrorDetail{Code: codeInvalidRequest, Message: invalid.Message, Path: invalid.Path}
+		}
+	}
+	var req models.IdentifyRequest
+	if err := decodeJSON(bytes.NewReader(raw), &req); err != nil {
+		return nil, &models.ErrorDetail{Code: codeInvalidJSON, Message: decodeErrorMessage(err)}
+	}
+	if (req.Email == nil || *req.Email == "") && (req.PhoneNumber == nil || *req.PhoneNumber == "") {
+		return nil, &models.ErrorDetail{Code: codeMissingIdentifier, Message: "Either email or phoneNumber must be provided"}
+	}
+	if err := req.Validate(); err != nil {
+		return nil, &models.ErrorDetail{Code: codeInvalidIdentifier, Message: err.Error()}
+	}
+
+	response, err := h.service.Identify(ctx, req, opts)
+	var conflictErr *service.ConflictError
+	switch {
+	case err == nil:
+		metrics.IdentifyRequests.WithLabelValues(response.Outcome).Inc()
+		h.service.RecordRequest(raw, response.Contact.PrimaryContactID)
+		return response, nil
+	case errors.Is(err, service.ErrInvalidEmail):
+		return nil, &models.ErrorDetail{Code: codeInvalidEmail, Message: err.Error()}
+	case errors.Is(err, service.ErrSwappedFields):
+		return nil, &models.ErrorDetail{Code: codeSwappedFields, Message: err.Error()}
+	case errors.As(err, &conflictErr):
+		detail := conflictDetail(conflictErr)
+		return nil, &detail
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, &models.ErrorDetail{Code: codeTimeout, Message: "Database timeout exceeded"}
+	case errors.Is(err, context.Canceled):
+		return nil, &models.ErrorDetail{Code: codeCanceled, Message: "The request was canceled before this element finished"}
+	default:
+		requestid.Logf(ctx, "Error processing bulk identify element: %v", err)
+		return nil, &models.ErrorDetail{Code: codeInternal, Message: fmt.Sprintf("Internal server error: %v", err)}
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"bitespeed/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+// ContactsHandler handles the /contacts/{id} endpoints
+type ContactsHandler struct {
+	service *service.ReconciliationService
+}
+
+// NewContactsHandler creates a new contacts handler
+func NewContactsHandler(svc *service.ReconciliationService) *ContactsHandler {
+	return &ContactsHandler{service: svc}
+}
+
+// Lineage returns the former primaries absorbed into the contact's cluster
+func (h *ContactsHandler) Lineage(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseContactID(w, r)
+	if !ok {
+		return
+	}
+
+	lineage, err := h.service.Lineage(r.Context(), id)
+	if errors.Is(err, service.ErrContactNotFound) {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching lineage for contact %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(lineage); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// Get returns the reconciled identity of an active contact, primary or secondary
+func (h *ContactsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseContactID(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := h.service.GetByID(r.Context(), id)
+	if errors.Is(err, service.ErrContactNotFound) {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching contact %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeIdentifyResponse(w, r, response)
+}
+
+// Cluster returns the consolidated response of the contact's primary, for any contact in the cluster
+func (h *ContactsHandler) Cluster(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseContactID(w, r)
+	if !ok {
+		return
+	}
+
+	opts := service.IdentifyOptions{IncludeHistorical: r.URL.Query().Get("includeHistorical") == "true"}
+	response, err := h.service.Cluster(r.Context(), id, opts)
+	if errors.Is(err, service.ErrContactNotFound) {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching cluster for contact %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeIdentifyResponse(w, r, response)
+}
+
+// CRM returns the contact's cluster flattened into a single CRM record
+func (h *ContactsHandler) CRM(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseContactID(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := h.service.CRMRecord(r.Context(), id)
+	if errors.Is(err, service.ErrContactNotFound) {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error building CRM record for contact %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(record); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// Bridges returns the identifiers holding the contact's cluster together
+func (h *ContactsHandler) Bridges(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseContactID(w, r)
+	if !ok {
+		return
+	}
+
+	bridges, err := h.service.Bridges(r.Context(), id)
+	if errors.Is(err, service.ErrContactNotFound) {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding bridges for contact %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(bridges); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// Diagnostics returns a support-facing summary of the contact's cluster
+func (h *ContactsHandler) Diagnostics(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseContactID(w, r)
+	if !ok {
+		return
+	}
+
+	diagnostics, err := h.service.Diagnostics(r.Context(), id)
+	if errors.Is(err, service.ErrContactNotFound) {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error computing diagnostics for contact %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(diagnostics); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// parseContactID reads the {id} route variable, writing a 400 when it is invalid
+func parseContactID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid contact id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"bitespeed/internal/requestid"
+)
+
+// Headers announced to browsers by CORS responses
+var (
+	corsAllowMethods  = "GET, POST, OPTIONS"
+	corsAllowHeaders  = strings.Join([]string{"Content-Type", "Accept", "Authorization", "X-Primary-Strategy", requestid.Header}, ", ")
+	corsExposeHeaders = strings.Join([]string{requestid.Header, "Retry-After"}, ", ")
+)
+
+// CORS lets browser pages on allowed origins call the routes it wraps
+type CORS struct {
+	origins []string
+}
+
+// NewCORS creates a CORS policy for the given origins, such as
+// "https://app.example.com"; "*" allows any origin
+func NewCORS(origins []string) *CORS {
+	return &CORS{origins: origins}
+}
+
+// allowed reports whether a request's Origin may read the response
+func (c *CORS) allowed(origin string) bool {
+	return origin != "" && (slices.Contains(c.origins, "*") || slices.Contains(c.origins, strings.ToLower(origin)))
+}
+
+// Wrap adds the CORS headers to the responses of next, including its errors
+func (c *CORS) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); c.allowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		}
+		next(w, r)
+	}
+}
+
+// Preflight answers the OPTIONS request browsers send before a cross-origin
+// POST or a request with custom headers; disallowed origins get no CORS
+// headers, so the browser blocks the actual request
+func (c *CORS) Preflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Origin")
+	if origin := r.Header.Get("Origin"); c.allowed(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		w.Header().Set("Access-Control-Max-Age", "600")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
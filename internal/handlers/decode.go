@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"bitespeed/internal/models"
+)
+
+// errTrailingData is returned when a body holds more than one JSON value
+var errTrailingData = errors.New("unexpected data after the JSON object")
+
+// decodeJSON decodes a single JSON value, rejecting unknown object fields (a
+// typo such as "phonenumber" would otherwise be silently ignored) and trailing
+// data such as a second object; trailing whitespace is allowed
+func decodeJSON(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return errTrailingData
+	}
+	return checkFieldNames(data, v)
+}
+
+// checkFieldNames rejects object keys that only match a struct field when case
+// is ignored, which encoding/json accepts even with DisallowUnknownFields
+func checkFieldNames(data []byte, v any) error {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var fields map[string]json.RawMessage
+	if t.Kind() != reflect.Struct || json.Unmarshal(data, &fields) != nil {
+		return nil
+	}
+
+	known := make(map[string]bool, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		known[name] = true
+	}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		if !known[key] {
+			return fmt.Errorf("json: unknown field %q", key)
+		}
+	}
+	return nil
+}
+
+// decodeErrorMessage turns a json.Decoder error into a client-facing message that
+// names the offending field, the expected type and the byte offset where known
+func decodeErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, errTrailingData), errors.Is(err, models.ErrInvalidPhoneNumberType):
+		return "Invalid JSON: " + err.Error()
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "Invalid JSON: " + strings.TrimPrefix(err.Error(), "json: ")
+	case errors.Is(err, io.EOF):
+		return "Invalid JSON: request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "Invalid JSON: unexpected end of input"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("Invalid JSON: syntax error at byte offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("Invalid JSON: request body must be %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value)
+		}
+		return fmt.Sprintf("Invalid JSON: field %q must be %s, got %s at byte offset %d",
+			typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value, typeErr.Offset)
+	default:
+		return "Invalid JSON"
+	}
+}
+
+// jsonTypeName describes a Go type with the JSON type name clients send
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return t.String()
+	}
+}
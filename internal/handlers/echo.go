@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EchoStatus repeats the HTTP status of every JSON or error response in its body
+// as "httpStatus" plus a "success" boolean, for clients that cannot read the
+// status line. JSON objects gain both fields, plaintext errors become
+// {"error": ..., "httpStatus": ..., "success": false} and other JSON values are
+// wrapped under "data". Non-JSON success responses such as /metrics and the
+// /export.csv stream are passed through unbuffered; only the responses it
+// rewrites are held in memory.
+func EchoStatus(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{w: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.passthrough {
+			return
+		}
+
+		body := rec.body.Bytes()
+		if echoed, ok := echoStatusBody(w.Header(), rec.status, body); ok {
+			body = echoed
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Del("X-Content-Type-Options")
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+		w.WriteHeader(rec.status)
+		if _, err := w.Write(body); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	})
+}
+
+// statusRecorder buffers a response so its body can be rewritten before
+// sending. Once the status and Content-Type show the body will be left as is,
+// it writes straight through to w instead.
+type statusRecorder struct {
+	w           http.ResponseWriter
+	status      int
+	wroteHeader bool
+	passthrough bool
+	body        bytes.Buffer
+}
+
+func (r *statusRecorder) Header() http.Header {
+	return r.w.Header()
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+	if status < http.StatusBadRequest && !strings.HasPrefix(r.w.Header().Get("Content-Type"), "application/json") {
+		r.passthrough = true
+		r.w.WriteHeader(status)
+	}
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	if r.passthrough {
+		return r.w.Write(b)
+	}
+	return r.body.Write(b)
+}
+
+// Flush sends what a passed-through response wrote so far; buffered responses
+// are only complete once the handler returns
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.w.(http.Flusher); ok && r.passthrough {
+		flusher.Flush()
+	}
+}
+
+// echoStatusBody returns the body with the status echoed, or false when the
+// response is left as is
+func echoStatusBody(header http.Header, status int, body []byte) ([]byte, bool) {
+	success := status < http.StatusBadRequest
+	isJSON := strings.HasPrefix(header.Get("Content-Type"), "application/json") && json.Valid(body)
+	if !isJSON && success {
+		return nil, false
+	}
+	fields := fmt.Sprintf(`"httpStatus":%d,"success":%t`, status, success)
+
+	if !isJSON {
+		message, _ := json.Marshal(strings.TrimSpace(string(body)))
+		return []byte(fmt.Sprintf(`{"error":%s,%s}`+"\n", message, fields)), true
+	}
+
+	// Splice the fields into objects so the existing key order is kept
+	trimmed := bytes.TrimSpace(body)
+	if trimmed[0] != '{' {
+		return []byte(fmt.Sprintf(`{"data":%s,%s}`+"\n", trimmed, fields)), true
+	}
+	inner := bytes.TrimSpace(trimmed[1 : len(trimmed)-1])
+	if len(inner) == 0 {
+		return []byte("{" + fields + "}\n"), true
+	}
+	return []byte(fmt.Sprintf("{%s,%s}\n", inner, fields)), true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEchoStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        string
+	}{
+		{name: "JSON success", status: http.StatusOK, contentType: "application/json", body: `{"contact":{}}`, want: `{"contact":{},"httpStatus":200,"success":true}` + "\n"},
+		{name: "JSON error", status: http.StatusBadRequest, contentType: "application/json", body: `{"error":{}}`, want: `{"error":{},"httpStatus":400,"success":false}` + "\n"},
+		{name: "JSON array", status: http.StatusOK, contentType: "application/json", body: `[1]`, want: `{"data":[1],"httpStatus":200,"success":true}` + "\n"},
+		{name: "plaintext error", status: http.StatusNotFound, contentType: "text/plain", body: "Contact not found\n", want: `{"error":"Contact not found","httpStatus":404,"success":false}` + "\n"},
+		{name: "CSV passed through", status: http.StatusOK, contentType: "text/csv", body: "id,email\n1,doc@hillvalley.edu\n", want: "id,email\n1,doc@hillvalley.edu\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := EchoStatus(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoStatusStreamsNonJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handler := EchoStatus(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("id,email\n"))
+		w.(http.Flusher).Flush()
+
+		// The first rows reach the client while the handler is still writing
+		if !rec.Flushed || !strings.HasPrefix(rec.Body.String(), "id,email") {
+			t.Errorf("nothing sent before the handler returned: %q", rec.Body)
+		}
+		w.Write([]byte("1,doc@hillvalley.edu\n"))
+	}))
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export.csv", nil))
+
+	if got := rec.Body.String(); got != "id,email\n1,doc@hillvalley.edu\n" {
+		t.Errorf("body = %q", got)
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("streamed response got a Content-Length")
+	}
+}
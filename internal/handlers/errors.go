@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"bitespeed/internal/models"
+	"bitespeed/internal/requestid"
+)
+
+// Error codes of JSON error responses
+const (
+	codeNotFound               = "NOT_FOUND"
+	codeMethodNotAllowed       = "METHOD_NOT_ALLOWED"
+	codeUnauthorized           = "UNAUTHORIZED"
+	codeInvalidBody            = "INVALID_BODY"
+	codeInvalidJSON            = "INVALID_JSON"
+	codeMissingIdentifier      = "MISSING_IDENTIFIER"
+	codeInvalidIdentifier      = "INVALID_IDENTIFIER"
+	codeInvalidEmail           = "INVALID_EMAIL"
+	codeSwappedFields          = "SWAPPED_FIELDS"
+	codeInvalidPrimaryStrategy = "INVALID_PRIMARY_STRATEGY"
+	codeInvalidPagination      = "INVALID_PAGINATION"
+	codeBatchTooLarge          = "BATCH_TOO_LARGE"
+	codeBodyTooLarge           = "BODY_TOO_LARGE"
+	codeRateLimited            = "RATE_LIMITED"
+	codeInvalidRequest         = "INVALID_REQUEST"
+	codeUnsupportedMediaType   = "UNSUPPORTED_MEDIA_TYPE"
+	codeTimeout                = "TIMEOUT"
+	codeCanceled               = "CANCELED"
+	codeInternal               = "INTERNAL_ERROR"
+)
+
+// writeError responds with {"error": {"code": ..., "message": ..., "requestId": ...}}
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeErrorDetail(w, r, status, models.ErrorDetail{Code: code, Message: message})
+}
+
+// writeErrorDetail responds with the error detail, tagged with the request id
+func writeErrorDetail(w http.ResponseWriter, r *http.Request, status int, detail models.ErrorDetail) {
+	detail.RequestID = requestid.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(models.ErrorResponse{Error: detail}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// exportColumns is the header row of /export.csv
+var exportColumns = []string{"id", "phoneNumber", "email", "linkedId", "linkPrecedence", "createdAt"}
+
+// ExportCSV streams every active contact as CSV, one row per contact in id
+// order. Rows are written as they are read, so the table is never buffered;
+// an error after the first row can only end the download early.
+func (h *AdminHandler) ExportCSV(w http.ResponseWriter, r *http.Request) {
+	writer := csv.NewWriter(w)
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="contacts.csv"`)
+		w.WriteHeader(http.StatusOK)
+		return writer.Write(exportColumns)
+	}
+
+	err := h.service.ExportContacts(r.Context(), func(c *models.Contact) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.Write(exportRecord(c))
+	})
+	if err != nil && !started {
+		log.Printf("Error exporting contacts: %v", err)
+		http.Error(w, "Failed to export contacts", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		log.Printf("Export of contacts aborted: %v", err)
+		return
+	}
+	if !started {
+		if err := start(); err != nil {
+			log.Printf("Error writing export: %v", err)
+			return
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing export: %v", err)
+	}
+}
+
+// exportRecord formats a contact as a CSV row matching exportColumns
+func exportRecord(c *models.Contact) []string {
+	var phone, email, linkedID string
+	if c.PhoneNumber != nil {
+		phone = *c.PhoneNumber
+	}
+	if c.Email != nil {
+		email = *c.Email
+	}
+	if c.LinkedID != nil {
+		linkedID = strconv.FormatInt(*c.LinkedID, 10)
+	}
+	return []string{
+		strconv.FormatInt(c.ID, 10),
+		phone,
+		email,
+		linkedID,
+		c.LinkPrecedence,
+		c.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"bitespeed/internal/database"
+)
+
+// healthPingTimeout bounds the database checks of a readiness probe
+const healthPingTimeout = 2 * time.Second
+
+// healthResponse is the body of the probes; the dialect and latency are only
+// reported by a passing readiness check
+type healthResponse struct {
+	Status    string  `json:"status"`
+	Dialect   string  `json:"dialect,omitempty"`
+	LatencyMS float64 `json:"latencyMs,omitempty"`
+}
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	db *database.DB
+}
+
+// NewHealthHandler creates a new probe handler
+func NewHealthHandler(db *database.DB) *HealthHandler {
+	return &HealthHandler{db: db}
+}
+
+// Live reports that the process is up and serving requests
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Ready checks that the database answers and is migrated, answering 503 when
+// it does not respond in time or the contacts table is missing
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthPingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	status := http.StatusOK
+	response := healthResponse{Status: "ok", Dialect: h.db.Dialect()}
+	if err := h.db.Ready(ctx); err != nil {
+		log.Printf("Readiness check failed: %v", err)
+		status = http.StatusServiceUnavailable
+		response = healthResponse{Status: "unhealthy"}
+	} else {
+		response.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	}
+	writeHealth(w, status, response)
+}
+
+// writeHealth writes a probe response
+func writeHealth(w http.ResponseWriter, status int, response healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
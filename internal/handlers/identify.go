@@ -1,58 +1,181 @@
 package handlers
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
-	"bitespeed/internal/database"
+	"bitespeed/internal/metrics"
 	"bitespeed/internal/models"
+	"bitespeed/internal/openapi"
+	"bitespeed/internal/requestid"
 	"bitespeed/internal/service"
 )
 
 // IdentifyHandler handles the /identify endpoint
 type IdentifyHandler struct {
-	service *service.ReconciliationService
+	service      *service.ReconciliationService
+	maxBodyBytes int64
+	// elementSchema checks each /bulk-identify element like a single
+	// /identify body; nil skips the check
+	elementSchema *openapi.Operation
 }
 
-// NewIdentifyHandler creates a new identify handler
-func NewIdentifyHandler(db *database.DB) *IdentifyHandler {
-	return &IdentifyHandler{
-		service: service.NewReconciliationService(db),
+// NewIdentifyHandler creates a new identify handler rejecting bodies larger
+// than maxBodyBytes. When spec is not nil, bulk elements are validated against
+// its /identify schema; single requests are validated by RequestValidator.
+func NewIdentifyHandler(svc *service.ReconciliationService, maxBodyBytes int64, spec *openapi.Spec) *IdentifyHandler {
+	h := &IdentifyHandler{service: svc, maxBodyBytes: maxBodyBytes}
+	if spec != nil {
+		h.elementSchema, _ = spec.Operation("/identify", http.MethodPost)
 	}
+	return h
 }
 
 // Handle processes the identify request
 func (h *IdentifyHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Keep the exact payload for the audit log
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if writeBodyTooLarge(w, r, err) {
+		return
+	}
+	if err != nil {
+		requestid.Logf(r.Context(), "Error reading request: %v", err)
+		writeError(w, r, http.StatusBadRequest, codeInvalidBody, "Failed to read request body")
+		return
+	}
+
+	// An empty body lacks identifiers rather than being malformed JSON
+	if len(bytes.TrimSpace(raw)) == 0 {
+		writeError(w, r, http.StatusBadRequest, codeMissingIdentifier, "Either email or phoneNumber must be provided (request body is empty)")
 		return
 	}
 
 	var req models.IdentifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding request: %v", err)
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := decodeJSON(bytes.NewReader(raw), &req); err != nil {
+		requestid.Logf(r.Context(), "Error decoding request: %v", err)
+		writeError(w, r, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
 		return
 	}
 
 	// Validate request - at least one of email or phoneNumber must be provided
 	if (req.Email == nil || *req.Email == "") && (req.PhoneNumber == nil || *req.PhoneNumber == "") {
-		http.Error(w, "Either email or phoneNumber must be provided", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeMissingIdentifier, "Either email or phoneNumber must be provided")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidIdentifier, err.Error())
+		return
+	}
+
+	opts, ok := identifyOptions(w, r)
+	if !ok {
 		return
 	}
+	// Only single requests can be dry-run: bulk elements build on each other's writes
+	opts.DryRun = r.URL.Query().Get("dryRun") == "true"
 
-	response, err := h.service.Identify(req)
+	response, err := h.service.Identify(r.Context(), req, opts)
+	if errors.Is(err, service.ErrInvalidEmail) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidEmail, err.Error())
+		return
+	}
+	if errors.Is(err, service.ErrSwappedFields) {
+		writeError(w, r, http.StatusBadRequest, codeSwappedFields, err.Error())
+		return
+	}
+	var conflictErr *service.ConflictError
+	if errors.As(err, &conflictErr) {
+		writeConflict(w, r, conflictErr)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		requestid.Logf(r.Context(), "Identify request timed out: %v", err)
+		writeError(w, r, http.StatusGatewayTimeout, codeTimeout, "Database timeout exceeded")
+		return
+	}
 	if err != nil {
-		log.Printf("Error processing identify request: %v", err)
-		http.Error(w, fmt.Sprintf("Internal server error: %v", err), http.StatusInternalServerError)
+		requestid.Logf(r.Context(), "Error processing identify request: %v", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternal, fmt.Sprintf("Internal server error: %v", err))
 		return
 	}
+	if !opts.DryRun {
+		metrics.IdentifyRequests.WithLabelValues(response.Outcome).Inc()
+		h.service.RecordRequest(raw, response.Contact.PrimaryContactID)
+	}
+
+	writeIdentifyResponse(w, r, response)
+}
+
+// writeBodyTooLarge answers 413 and returns true when err reports a body over
+// the http.MaxBytesReader limit
+func writeBodyTooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	writeError(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+	return true
+}
+
+// identifyOptions reads the per-request options from the query string and
+// headers, writing a 400 when they are invalid
+func identifyOptions(w http.ResponseWriter, r *http.Request) (service.IdentifyOptions, bool) {
+	opts := service.IdentifyOptions{
+		IncludeHistorical:   r.URL.Query().Get("includeHistorical") == "true",
+		EchoNormalizedInput: r.URL.Query().Get("echoInput") == "true",
+		Verbose:             r.URL.Query().Get("verbose") == "true",
+		Timestamps:          r.URL.Query().Get("timestamps") == "true",
+		PrimaryStrategy:     strings.ToLower(r.Header.Get("X-Primary-Strategy")),
+		RequestID:           requestid.FromContext(r.Context()),
+	}
+	if opts.PrimaryStrategy != "" && !service.ValidPrimaryStrategy(opts.PrimaryStrategy) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidPrimaryStrategy, fmt.Sprintf("Unknown primary strategy %q", opts.PrimaryStrategy))
+		return opts, false
+	}
+	paging := []struct {
+		param  string
+		target *int
+	}{{"limit", &opts.Limit}, {"offset", &opts.Offset}}
+	for _, p := range paging {
+		raw := r.URL.Query().Get(p.param)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			writeError(w, r, http.StatusBadRequest, codeInvalidPagination, p.param+" must be a non-negative integer")
+			return opts, false
+		}
+		*p.target = parsed
+	}
+	return opts, true
+}
+
+// responseStatus is 200, or 206 when the cluster could only be read partially
+func responseStatus(response *models.IdentifyResponse) int {
+	if response.Partial {
+		return http.StatusPartialContent
+	}
+	return http.StatusOK
+}
+
+// writeConflict responds 409 with the reason and the primaries that could not be reconciled
+func writeConflict(w http.ResponseWriter, r *http.Request, conflict *service.ConflictError) {
+	writeErrorDetail(w, r, http.StatusConflict, conflictDetail(conflict))
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response: %v", err)
+// conflictDetail is the error detail of a request that could not be reconciled
+func conflictDetail(conflict *service.ConflictError) models.ErrorDetail {
+	return models.ErrorDetail{
+		Code:                  conflict.Code,
+		Message:               conflict.Message,
+		ConflictingPrimaryIDs: conflict.PrimaryIDs,
+		ReviewID:              conflict.ReviewID,
 	}
 }
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/database"
+	"bitespeed/internal/models"
+	"bitespeed/internal/service"
+)
+
+// testDBSeq names each test's in-memory database so tests don't share one
+var testDBSeq atomic.Int64
+
+// newTestService returns a service backed by a fresh in-memory SQLite database
+// with the default configuration, adjusted by configure when it is not nil
+func newTestService(t *testing.T, configure func(*config.Config)) *service.ReconciliationService {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	dsn := fmt.Sprintf("file:handlers-test-%d?mode=memory&cache=shared", testDBSeq.Add(1))
+	db, err := database.New(dsn, database.Options{MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := service.NewReconciliationService(db, cfg)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	return s
+}
+
+// errorCode returns the code of a {"error": {...}} response, or "" for any
+// other body
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body models.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return ""
+	}
+	return body.Error.Code
+}
+
+func TestIdentifyRejectsBadBodies(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "valid", body: `{"email":"doc@hillvalley.edu"}`, status: http.StatusOK},
+		{name: "oversized", body: `{"email":"` + strings.Repeat("a", 128) + `@hillvalley.edu"}`, status: http.StatusRequestEntityTooLarge, code: codeBodyTooLarge},
+		{name: "unknown field", body: `{"email":"doc@hillvalley.edu","phonenumber":"111"}`, status: http.StatusBadRequest, code: codeInvalidJSON},
+		{name: "field in wrong case", body: `{"Email":"doc@hillvalley.edu"}`, status: http.StatusBadRequest, code: codeInvalidJSON},
+		{name: "trailing object", body: `{"email":"doc@hillvalley.edu"}{}`, status: http.StatusBadRequest, code: codeInvalidJSON},
+		{name: "empty", body: ``, status: http.StatusBadRequest, code: codeMissingIdentifier},
+	}
+
+	handler := NewIdentifyHandler(newTestService(t, nil), 64, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.Handle(rec, httptest.NewRequest(http.MethodPost, "/identify", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if code := errorCode(t, rec); code != tt.code {
+				t.Errorf("error code = %q, want %q", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestIdentifyConflictUsesErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name string
+		bulk bool
+	}{
+		{name: "identify"},
+		{name: "bulk element", bulk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewIdentifyHandler(newTestService(t, func(cfg *config.Config) {
+				cfg.ConflictPolicy = config.ConflictPolicyFlag
+			}), 1<<10, nil)
+			// Two established clusters: primaries 1 and 3, each with a secondary
+			for _, body := range []string{
+				`{"email":"doc@hillvalley.edu","phoneNumber":"111"}`,
+				`{"email":"emmett@hillvalley.edu","phoneNumber":"111"}`,
+				`{"email":"marty@hillvalley.edu","phoneNumber":"222"}`,
+				`{"email":"marty@hillvalley.edu","phoneNumber":"333"}`,
+			} {
+				rec := httptest.NewRecorder()
+				handler.Handle(rec, httptest.NewRequest(http.MethodPost, "/identify", strings.NewReader(body)))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("seeding %s: status = %d: %s", body, rec.Code, rec.Body)
+				}
+			}
+
+			body := `{"email":"doc@hillvalley.edu","phoneNumber":"222"}`
+			rec := httptest.NewRecorder()
+			var detail models.ErrorDetail
+			if tt.bulk {
+				handler.BulkHandle(rec, httptest.NewRequest(http.MethodPost, "/bulk-identify", strings.NewReader("["+body+"]")))
+				var results []models.ErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil || len(results) != 1 {
+					t.Fatalf("failed to decode %s: %v", rec.Body, err)
+				}
+				detail = results[0].Error
+			} else {
+				handler.Handle(rec, httptest.NewRequest(http.MethodPost, "/identify", strings.NewReader(body)))
+				if rec.Code != http.StatusConflict {
+					t.Fatalf("status = %d, want 409: %s", rec.Code, rec.Body)
+				}
+				var response models.ErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to decode %s: %v", rec.Body, err)
+				}
+				detail = response.Error
+			}
+
+			if detail.Code != service.ConflictReviewRequired || detail.Message == "" || detail.ReviewID == 0 {
+				t.Errorf("error = %+v, want %s with a message and review id", detail, service.ConflictReviewRequired)
+			}
+			if !slices.Equal(detail.ConflictingPrimaryIDs, []int64{1, 3}) {
+				t.Errorf("conflictingPrimaryIds = %v, want [1 3]", detail.ConflictingPrimaryIDs)
+			}
+		})
+	}
+}
+
+func TestBulkIdentifyStopsWhenCanceled(t *testing.T) {
+	svc := newTestService(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The third element merges the clusters of the first two; asking the
+	// resolver about it stands in for the client going away
+	svc.SetConflictResolver(service.ConflictResolverFunc(func(context.Context, models.ClusterConflict) (service.ConflictDecision, error) {
+		cancel()
+		return service.DecisionMerge, nil
+	}), time.Second, service.DecisionMerge)
+
+	body := `[{"email":"doc@hillvalley.edu"},{"phoneNumber":"222"},{"email":"doc@hillvalley.edu","phoneNumber":"222"},{"email":"marty@hillvalley.edu"}]`
+	rec := httptest.NewRecorder()
+	handler := NewIdentifyHandler(svc, 1<<10, nil)
+	handler.BulkHandle(rec, httptest.NewRequest(http.MethodPost, "/bulk-identify", strings.NewReader(body)).WithContext(ctx))
+
+	var results []models.BulkIdentifyResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil || len(results) != 4 {
+		t.Fatalf("failed to decode %s: %v", rec.Body, err)
+	}
+	for i, want := range []string{"", "", codeCanceled, codeCanceled} {
+		code := ""
+		if results[i].Error != nil {
+			code = results[i].Error.Code
+		}
+		if code != want {
+			t.Errorf("result %d code = %q, want %q", i, code, want)
+		}
+	}
+	if !strings.Contains(results[3].Error.Message, "after 3 of 4") {
+		t.Errorf("message %q does not say where the batch stopped", results[3].Error.Message)
+	}
+
+	// The elements before the cancellation stay committed, the rest never ran
+	for email, want := range map[string]bool{"doc@hillvalley.edu": true, "marty@hillvalley.edu": false} {
+		if _, found, err := svc.FindPrimaryID(context.Background(), &email, nil, nil); err != nil || found != want {
+			t.Errorf("%s stored = %v (%v), want %v", email, found, err, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bitespeed/internal/models"
+)
+
+// jsonAPIMediaType is the JSON:API media type negotiated through Accept
+const jsonAPIMediaType = "application/vnd.api+json"
+
+// wantsJSONAPI reports whether the Accept header asks for JSON:API documents
+func wantsJSONAPI(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == jsonAPIMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeIdentifyResponse encodes a consolidated contact in the native shape, or
+// as a JSON:API document when the client asks for one
+func writeIdentifyResponse(w http.ResponseWriter, r *http.Request, response *models.IdentifyResponse) {
+	var body any = response
+	w.Header().Set("Content-Type", "application/json")
+	if wantsJSONAPI(r) {
+		body = toJSONAPI(response)
+		w.Header().Set("Content-Type", jsonAPIMediaType)
+	}
+
+	w.WriteHeader(responseStatus(response))
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// toJSONAPI maps the primary to a "contact" resource whose secondaries are
+// listed as resource identifiers
+func toJSONAPI(response *models.IdentifyResponse) models.JSONAPIDocument {
+	contact := response.Contact
+	secondaries := make([]models.JSONAPIResourceID, 0, len(contact.SecondaryContactIDs))
+	for _, id := range contact.SecondaryContactIDs {
+		secondaries = append(secondaries, models.JSONAPIResourceID{Type: "contact", ID: strconv.FormatInt(id, 10)})
+	}
+
+	doc := models.JSONAPIDocument{
+		Data: models.JSONAPIContact{
+			Type: "contact",
+			ID:   strconv.FormatInt(contact.PrimaryContactID, 10),
+			Attributes: models.JSONAPIAttributes{
+				Emails:                 contact.Emails,
+				PhoneNumbers:           contact.PhoneNumbers,
+				VerifiedEmails:         contact.VerifiedEmails,
+				VerifiedPhoneNumbers:   contact.VerifiedPhoneNumbers,
+				HistoricalEmails:       contact.HistoricalEmails,
+				HistoricalPhoneNumbers: contact.HistoricalPhoneNumbers,
+				ClusterCreatedAt:       contact.ClusterCreatedAt,
+				ClusterUpdatedAt:       contact.ClusterUpdatedAt,
+			},
+			Relationships: models.JSONAPIRelationships{
+				Secondaries: models.JSONAPIRelationship{Data: secondaries},
+			},
+		},
+	}
+	if response.Resolution != "" || response.NormalizedInput != nil || response.Partial {
+		doc.Meta = &models.JSONAPIMeta{
+			Resolution:      response.Resolution,
+			NormalizedInput: response.NormalizedInput,
+			Partial:         response.Partial,
+		}
+	}
+	return doc
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// NotFound answers requests for unknown paths with a 404 JSON error
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, codeNotFound, "No route matches "+r.URL.Path)
+}
+
+// MethodNotAllowed answers requests whose path matches a route of router but
+// not its method with a 405 JSON error and an Allow header listing the
+// methods the path accepts
+func MethodNotAllowed(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowed := allowedMethods(router, r); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+		}
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, fmt.Sprintf("Method %s is not allowed", r.Method))
+	})
+}
+
+// allowedMethods collects the methods of every route matching the request's path
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	var allowed []string
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			candidate := r.Clone(r.Context())
+			candidate.Method = method
+			if route.Match(candidate, &mux.RouteMatch{}) {
+				allowed = append(allowed, method)
+			}
+		}
+		return nil
+	})
+	slices.Sort(allowed)
+	return slices.Compact(allowed)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"bitespeed/internal/service"
+)
+
+// PrimaryHandler handles the lightweight /primary lookup endpoint
+type PrimaryHandler struct {
+	service *service.ReconciliationService
+}
+
+// NewPrimaryHandler creates a new primary lookup handler
+func NewPrimaryHandler(svc *service.ReconciliationService) *PrimaryHandler {
+	return &PrimaryHandler{service: svc}
+}
+
+// Handle returns the primary contact id for ?email= or ?phoneNumber=, optionally scoped by ?accountId=
+func (h *PrimaryHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	email := query.Get("email")
+	phoneNumber := query.Get("phoneNumber")
+
+	if (email == "") == (phoneNumber == "") {
+		http.Error(w, "Exactly one of email or phoneNumber must be provided", http.StatusBadRequest)
+		return
+	}
+
+	var emailPtr, phonePtr *string
+	if email != "" {
+		emailPtr = &email
+	} else {
+		phonePtr = &phoneNumber
+	}
+
+	var accountPtr *string
+	if accountID := query.Get("accountId"); accountID != "" {
+		accountPtr = &accountID
+	}
+
+	primaryID, found, err := h.service.FindPrimaryID(r.Context(), emailPtr, phonePtr, accountPtr)
+	if errors.Is(err, service.ErrInvalidEmail) {
+		writeError(w, r, http.StatusBadRequest, codeInvalidEmail, err.Error())
+		return
+	}
+	if errors.Is(err, service.ErrSwappedFields) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var conflictErr *service.ConflictError
+	if errors.As(err, &conflictErr) {
+		writeConflict(w, r, conflictErr)
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up primary contact: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+
+	// Primaries only change on merges, so a short cache lifetime is safe
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]int64{"primaryContactId": primaryID}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FairQueue limits how many requests run at once and lets the rest wait in
+// arrival order. Requests are shed with 503 when the queue is full or when they
+// have waited longer than the maximum wait.
+type FairQueue struct {
+	mu       sync.Mutex
+	limit    int
+	capacity int
+	maxWait  time.Duration
+	active   int
+	waiting  []chan struct{}
+}
+
+// NewFairQueue creates a queue running at most limit requests concurrently, with
+// up to capacity requests waiting at most maxWait each
+func NewFairQueue(limit, capacity int, maxWait time.Duration) *FairQueue {
+	return &FairQueue{limit: limit, capacity: capacity, maxWait: maxWait}
+}
+
+// Wrap runs next once the request reaches the front of the queue
+func (q *FairQueue) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !q.acquire(r) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server busy, please retry", http.StatusServiceUnavailable)
+			return
+		}
+		defer q.release()
+
+		next(w, r)
+	}
+}
+
+// acquire takes a slot, waiting in FIFO order; it reports false when the request was shed
+func (q *FairQueue) acquire(r *http.Request) bool {
+	q.mu.Lock()
+	if q.active < q.limit && len(q.waiting) == 0 {
+		q.active++
+		q.mu.Unlock()
+		return true
+	}
+	if len(q.waiting) >= q.capacity {
+		q.mu.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	q.waiting = append(q.waiting, ready)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(q.maxWait)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, waiter := range q.waiting {
+		if waiter == ready {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return false
+		}
+	}
+
+	// The slot was handed over while giving up; pass it on
+	q.handOff()
+	return false
+}
+
+// release frees the caller's slot
+func (q *FairQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handOff()
+}
+
+// handOff gives the current slot to the oldest waiter, or frees it; q.mu must be held
+func (q *FairQueue) handOff() {
+	if len(q.waiting) == 0 {
+		q.active--
+		return
+	}
+	next := q.waiting[0]
+	q.waiting = q.waiting[1:]
+	close(next)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitIdleTTL is how long a client's bucket is kept after its last
+// request; a full bucket that has refilled carries no state worth keeping
+const rateLimitIdleTTL = 10 * time.Minute
+
+// RateLimiter gives every client IP its own token bucket and answers 429 with
+// Retry-After once the bucket is empty. Buckets idle for rateLimitIdleTTL are
+// evicted on a sweep every rateLimitIdleTTL.
+type RateLimiter struct {
+	mu         sync.Mutex
+	limit      rate.Limit
+	burst      int
+	trustProxy bool
+	clients    map[string]*rateLimitClient
+	lastSweep  time.Time
+}
+
+// rateLimitClient is one IP's bucket and when it was last used
+type rateLimitClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perSecond requests per client IP
+// with bursts of up to burst. With trustProxy the client IP is taken from
+// X-Forwarded-For, which is only safe behind a proxy that sets it.
+func NewRateLimiter(perSecond float64, burst int, trustProxy bool) *RateLimiter {
+	return &RateLimiter{
+		limit:      rate.Limit(perSecond),
+		burst:      burst,
+		trustProxy: trustProxy,
+		clients:    make(map[string]*rateLimitClient),
+		lastSweep:  time.Now(),
+	}
+}
+
+// Wrap runs next while the client's bucket has a token left
+func (l *RateLimiter) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reservation := l.limiter(l.clientIP(r)).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, codeRateLimited, "Too many requests, please retry later")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// limiter returns the bucket of a client IP, creating it on first use
+func (l *RateLimiter) limiter(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= rateLimitIdleTTL {
+		for key, client := range l.clients {
+			if now.Sub(client.lastSeen) >= rateLimitIdleTTL {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &rateLimitClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = now
+	return client.limiter
+}
+
+// clientIP identifies the caller. Behind a trusted proxy it is the last
+// X-Forwarded-For entry, the one the proxy appended; earlier entries come from
+// the client and can be forged.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
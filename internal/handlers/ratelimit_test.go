@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimiter(t *testing.T) {
+	const burst = 3
+	tests := []struct {
+		name       string
+		trustProxy bool
+		// forwarded is the X-Forwarded-For header of request i, if any
+		forwarded func(i int) string
+		limited   bool
+	}{
+		{name: "same client", limited: true},
+		{name: "forwarded header ignored without a proxy", forwarded: func(i int) string { return "203.0.113.1" }, limited: true},
+		{name: "same client behind proxy", trustProxy: true, forwarded: func(i int) string { return "203.0.113.1" }, limited: true},
+		{name: "forged entries ignored behind proxy", trustProxy: true, forwarded: func(i int) string { return string(rune('a'+i)) + ", 203.0.113.1" }, limited: true},
+		{name: "distinct clients behind proxy", trustProxy: true, forwarded: func(i int) string { return "203.0.113." + string(rune('1'+i)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A rate this low refills no token during the test
+			handler := NewRateLimiter(0.001, burst, tt.trustProxy).Wrap(func(w http.ResponseWriter, r *http.Request) {})
+
+			for i := range burst + 1 {
+				req := httptest.NewRequest(http.MethodPost, "/identify", nil)
+				req.RemoteAddr = "192.0.2.1:4321"
+				if tt.forwarded != nil {
+					req.Header.Set("X-Forwarded-For", tt.forwarded(i))
+				}
+				rec := httptest.NewRecorder()
+				handler(rec, req)
+
+				want := http.StatusOK
+				if tt.limited && i == burst {
+					want = http.StatusTooManyRequests
+				}
+				if rec.Code != want {
+					t.Fatalf("request %d: status = %d, want %d", i+1, rec.Code, want)
+				}
+				if want == http.StatusTooManyRequests {
+					if rec.Header().Get("Retry-After") == "" {
+						t.Error("429 without a Retry-After header")
+					}
+					if code := errorCode(t, rec); code != codeRateLimited {
+						t.Errorf("error code = %q, want %q", code, codeRateLimited)
+					}
+				}
+			}
+		})
+	}
+}
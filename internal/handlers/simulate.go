@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+	"bitespeed/internal/service"
+)
+
+// SimulateHandler handles the development-only /simulate endpoint
+type SimulateHandler struct {
+	cfg *config.Config
+}
+
+// NewSimulateHandler creates a new simulate handler using the live configuration
+func NewSimulateHandler(cfg *config.Config) *SimulateHandler {
+	return &SimulateHandler{cfg: cfg}
+}
+
+// Handle replays a JSON array of identify requests on a throwaway database
+func (h *SimulateHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	var requests []models.IdentifyRequest
+	if err := decodeJSON(r.Body, &requests); err != nil {
+		http.Error(w, decodeErrorMessage(err), http.StatusBadRequest)
+		return
+	}
+	if len(requests) > service.MaxSimulationSteps {
+		http.Error(w, "Too many requests to simulate", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	result, err := service.Simulate(r.Context(), h.cfg, requests)
+	if err != nil {
+		log.Printf("Error running simulation: %v", err)
+		http.Error(w, "Simulation failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"bitespeed/internal/models"
+	"bitespeed/internal/openapi"
+)
+
+// RequestValidator checks request bodies against the OpenAPI spec before they
+// reach a handler, answering 400 with the JSON pointer of the offending value
+// or 415 for a non-JSON Content-Type
+type RequestValidator struct {
+	spec         *openapi.Spec
+	maxBodyBytes int64
+}
+
+// NewRequestValidator creates a validator for spec reading at most
+// maxBodyBytes of each body
+func NewRequestValidator(spec *openapi.Spec, maxBodyBytes int64) *RequestValidator {
+	return &RequestValidator{spec: spec, maxBodyBytes: maxBodyBytes}
+}
+
+// Wrap validates requests for operations the spec declares and passes the
+// body on to next unchanged
+func (v *RequestValidator) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		op, ok := v.spec.Operation(r.URL.Path, r.Method)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, v.maxBodyBytes))
+		if writeBodyTooLarge(w, r, err) {
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeInvalidBody, "Failed to read request body")
+			return
+		}
+
+		var invalid *openapi.ValidationError
+		if err := op.ValidateBody(r.Header.Get("Content-Type"), body); errors.As(err, &invalid) {
+			code := codeInvalidRequest
+			if invalid.Status == http.StatusUnsupportedMediaType {
+				code = codeUnsupportedMediaType
+			}
+			writeErrorDetail(w, r, invalid.Status, models.ErrorDetail{Code: code, Message: invalid.Message, Path: invalid.Path})
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r)
+	}
+}
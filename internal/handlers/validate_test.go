@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bitespeed/internal/models"
+	"bitespeed/internal/openapi"
+)
+
+func TestRequestValidator(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		pointer     string
+	}{
+		{name: "valid", path: "/identify", contentType: "application/json", body: `{"email":"doc@hillvalley.edu","phoneNumber":"111"}`, status: http.StatusOK},
+		{name: "numeric phone", path: "/identify", body: `{"phoneNumber":5551234}`, status: http.StatusOK},
+		{name: "null email", path: "/identify", contentType: "application/json; charset=utf-8", body: `{"email":null,"phoneNumber":"111"}`, status: http.StatusOK},
+		{name: "malformed JSON left to the handler", path: "/identify", body: `{"email":`, status: http.StatusOK},
+		{name: "undeclared path", path: "/bulk-identify", contentType: "text/plain", body: `[1]`, status: http.StatusOK},
+		{name: "email of wrong type", path: "/identify", body: `{"email":42}`, status: http.StatusBadRequest, code: codeInvalidRequest, pointer: "/email"},
+		{name: "phone of wrong type", path: "/identify", body: `{"phoneNumber":true}`, status: http.StatusBadRequest, code: codeInvalidRequest, pointer: "/phoneNumber"},
+		{name: "email too long", path: "/identify", body: `{"email":"` + strings.Repeat("a", 321) + `"}`, status: http.StatusBadRequest, code: codeInvalidRequest, pointer: "/email"},
+		{name: "unknown property", path: "/identify", body: `{"emial":"doc@hillvalley.edu"}`, status: http.StatusBadRequest, code: codeInvalidRequest, pointer: "/emial"},
+		{name: "not an object", path: "/identify", body: `["doc@hillvalley.edu"]`, status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "form body", path: "/identify", contentType: "application/x-www-form-urlencoded", body: `email=doc`, status: http.StatusUnsupportedMediaType, code: codeUnsupportedMediaType},
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded string
+			handler := NewRequestValidator(spec, 1<<10).Wrap(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				forwarded = string(body)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK {
+				if forwarded != tt.body {
+					t.Errorf("handler got body %q, want %q", forwarded, tt.body)
+				}
+				return
+			}
+			var body models.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode error: %v", err)
+			}
+			if body.Error.Code != tt.code || body.Error.Path != tt.pointer {
+				t.Errorf("error = %s at %q, want %s at %q", body.Error.Code, body.Error.Path, tt.code, tt.pointer)
+			}
+		})
+	}
+}
+
+func TestValidatorAgreesWithDecoder(t *testing.T) {
+	tests := []struct {
+		phoneNumber string
+		accepted    bool
+	}{
+		{phoneNumber: `"+915551234"`, accepted: true},
+		{phoneNumber: `"0915551234"`, accepted: true},
+		{phoneNumber: `5551234`, accepted: true},
+		{phoneNumber: `99999999999999999999999`, accepted: true},
+		{phoneNumber: `null`, accepted: true},
+		{phoneNumber: `9.1e9`},
+		{phoneNumber: `9100000000.0`},
+		{phoneNumber: `-5`},
+		{phoneNumber: `true`},
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, _ := spec.Operation("/identify", http.MethodPost)
+	for _, tt := range tests {
+		t.Run(tt.phoneNumber, func(t *testing.T) {
+			body := []byte(`{"phoneNumber":` + tt.phoneNumber + `}`)
+			validateErr := op.ValidateBody("application/json", body)
+			var req models.IdentifyRequest
+			decodeErr := json.Unmarshal(body, &req)
+
+			if (validateErr == nil) != tt.accepted || (decodeErr == nil) != tt.accepted {
+				t.Errorf("validator error %v, decoder error %v, want accepted %v", validateErr, decodeErr, tt.accepted)
+			}
+		})
+	}
+}
+
+func TestBulkIdentifyValidatesElements(t *testing.T) {
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewIdentifyHandler(newTestService(t, nil), 1<<10, spec)
+	body := `[{"email":42},{"email":"doc@hillvalley.edu","phoneNumber":-5},{"email":"doc@hillvalley.edu"}]`
+	rec := httptest.NewRecorder()
+	handler.BulkHandle(rec, httptest.NewRequest(http.MethodPost, "/bulk-identify", strings.NewReader(body)))
+
+	var results []models.BulkIdentifyResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil || len(results) != 3 {
+		t.Fatalf("failed to decode %s: %v", rec.Body, err)
+	}
+	for i, pointer := range []string{"/email", "/phoneNumber"} {
+		if failure := results[i].Error; failure == nil || failure.Code != codeInvalidRequest || failure.Path != pointer {
+			t.Errorf("result %d error = %+v, want %s at %q", i, failure, codeInvalidRequest, pointer)
+		}
+	}
+	if results[2].Error != nil || results[2].IdentifyResponse == nil {
+		t.Errorf("valid element after invalid ones failed: %+v", results[2].Error)
+	}
+}
@@ -0,0 +1,95 @@
+// Package metrics holds the Prometheus collectors exported on /metrics.
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// PrimaryDemotions counts primaries turned into secondaries by a merge. Merges
+// are rare, so a rising rate usually points at a matching bug.
+var PrimaryDemotions = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bitespeed_primary_demotions_total",
+	Help: "Primary contacts demoted to secondary during reconciliation.",
+})
+
+// IdentifyRequests counts successful identify calls, including bulk elements,
+// by what they did: created_primary, created_secondary, merged or no_change
+var IdentifyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bitespeed_identify_requests_total",
+	Help: "Successful identify requests by reconciliation outcome.",
+}, []string{"outcome"})
+
+// RequestDuration observes the latency of every routed request by route
+// template, method and status
+var RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "bitespeed_http_request_duration_seconds",
+	Help:    "Latency of HTTP requests.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"route", "method", "status"})
+
+// Handler serves the registered collectors in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterContactCount exports the number of active contacts, read through
+// count on every scrape
+func RegisterContactCount(count func() (int64, error)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "bitespeed_contacts",
+		Help: "Active (not soft-deleted) contacts.",
+	}, func() float64 {
+		n, err := count()
+		if err != nil {
+			log.Printf("Error counting contacts for metrics: %v", err)
+			return 0
+		}
+		return float64(n)
+	})
+}
+
+// Middleware times requests into RequestDuration. Register it with
+// Router.Use so routes are labelled by their template rather than their path.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		RequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusWriter remembers the status written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
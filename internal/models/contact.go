@@ -1,12 +1,20 @@
 package models
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
 
 // Contact represents a customer contact in the database
 type Contact struct {
 	ID             int64      `json:"id"`
 	PhoneNumber    *string    `json:"phoneNumber,omitempty"`
 	Email          *string    `json:"email,omitempty"`
+	AccountID      *string    `json:"accountId,omitempty"`
 	LinkedID       *int64     `json:"linkedId,omitempty"`
 	LinkPrecedence string     `json:"linkPrecedence"`
 	CreatedAt      time.Time  `json:"createdAt"`
@@ -18,17 +26,365 @@ type Contact struct {
 type IdentifyRequest struct {
 	Email       *string `json:"email"`
 	PhoneNumber *string `json:"phoneNumber"`
+	// AccountID scopes matching to one organization; unscoped requests only
+	// match unscoped contacts
+	AccountID *string `json:"accountId,omitempty"`
+	// EmailVerified/PhoneNumberVerified mark the sent identifiers as verified
+	// by the caller; verification is never removed by later requests
+	EmailVerified       bool `json:"emailVerified,omitempty"`
+	PhoneNumberVerified bool `json:"phoneNumberVerified,omitempty"`
 }
 
+// ErrInvalidPhoneNumberType is returned when phoneNumber is neither a string
+// nor a plain whole number, such as 12.5, -5, 9.1e9 or true
+var ErrInvalidPhoneNumberType = errors.New(`field "phoneNumber" must be a string or a whole number`)
+
+// UnmarshalJSON also accepts phoneNumber as a JSON number, which some clients
+// send, and stores its digits as the string form. Leading zeros and a leading
+// "+" cannot be expressed as a number, so those must still be sent as strings.
+func (r *IdentifyRequest) UnmarshalJSON(data []byte) error {
+	type plain IdentifyRequest
+	aux := struct {
+		*plain
+		PhoneNumber json.RawMessage `json:"phoneNumber"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	r.PhoneNumber = nil
+	if len(aux.PhoneNumber) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(aux.PhoneNumber))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+	case string:
+		r.PhoneNumber = &v
+	case json.Number:
+		// The same numbers the OpenAPI spec accepts: an integer of at least 0
+		number, ok := new(big.Int).SetString(v.String(), 10)
+		if !ok || number.Sign() < 0 {
+			return fmt.Errorf("%w, got %s", ErrInvalidPhoneNumberType, v)
+		}
+		digits := number.String()
+		r.PhoneNumber = &digits
+	case bool:
+		return fmt.Errorf("%w, got a boolean", ErrInvalidPhoneNumberType)
+	case []any:
+		return fmt.Errorf("%w, got an array", ErrInvalidPhoneNumberType)
+	default:
+		return fmt.Errorf("%w, got an object", ErrInvalidPhoneNumberType)
+	}
+	return nil
+}
+
+// LegacyPrimaryKey also serializes the primary id under the misspelled
+// "primaryContatctId" key older integrations parse (LEGACY_PRIMARY_KEY, off by
+// default)
+var LegacyPrimaryKey = false
+
 // ContactResponse represents the contact data in the response
 type ContactResponse struct {
-	PrimaryContactID    int64    `json:"primaryContatctId"`
+	PrimaryContactID    int64    `json:"primaryContactId"`
 	Emails              []string `json:"emails"`
 	PhoneNumbers        []string `json:"phoneNumbers"`
 	SecondaryContactIDs []int64  `json:"secondaryContactIds"`
+
+	// Identifiers of the cluster marked as verified; verified values are also
+	// listed first in Emails/PhoneNumbers
+	VerifiedEmails       []string `json:"verifiedEmails,omitempty"`
+	VerifiedPhoneNumbers []string `json:"verifiedPhoneNumbers,omitempty"`
+
+	// Earliest created_at and latest updated_at across the active cluster
+	ClusterCreatedAt time.Time `json:"clusterCreatedAt"`
+	ClusterUpdatedAt time.Time `json:"clusterUpdatedAt"`
+
+	// created_at and updated_at of the primary contact itself, populated when
+	// the request sets timestamps=true
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+
+	// Total holds the unpaged array lengths when the request pages the
+	// arrays with limit/offset
+	Total *ContactTotals `json:"total,omitempty"`
+
+	// Historical identifiers only found on soft-deleted cluster members,
+	// populated when the request sets includeHistorical=true
+	HistoricalEmails       []string `json:"historicalEmails,omitzero"`
+	HistoricalPhoneNumbers []string `json:"historicalPhoneNumbers,omitzero"`
+}
+
+// ContactTotals counts the entries of a paged ContactResponse's arrays
+type ContactTotals struct {
+	Emails              int `json:"emails"`
+	PhoneNumbers        int `json:"phoneNumbers"`
+	SecondaryContactIDs int `json:"secondaryContactIds"`
+}
+
+// MarshalJSON writes primaryContactId and, while LegacyPrimaryKey is set, the
+// legacy primaryContatctId key with the same value
+func (c ContactResponse) MarshalJSON() ([]byte, error) {
+	type plain ContactResponse
+	if !LegacyPrimaryKey {
+		return json.Marshal(plain(c))
+	}
+	return json.Marshal(struct {
+		LegacyPrimaryContactID int64 `json:"primaryContatctId"`
+		plain
+	}{c.PrimaryContactID, plain(c)})
+}
+
+// BulkIdentifyResult is one element of a /bulk-identify answer: the identify
+// response, or an error when that element failed
+type BulkIdentifyResult struct {
+	*IdentifyResponse
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+// NormalizedInput echoes the request identifiers after normalization
+type NormalizedInput struct {
+	Email       *string `json:"email"`
+	PhoneNumber *string `json:"phoneNumber"`
 }
 
 // IdentifyResponse represents the response body
 type IdentifyResponse struct {
-	Contact ContactResponse `json:"contact"`
+	Contact         ContactResponse  `json:"contact"`
+	NormalizedInput *NormalizedInput `json:"normalizedInput,omitempty"`
+
+	// Resolution reports what the identify did, when asked for with ?verbose=true
+	Resolution string `json:"resolution,omitempty"`
+
+	// Partial is set when some cluster members could not be read (PARTIAL_RESPONSES)
+	Partial bool `json:"partial,omitempty"`
+
+	// DryRun marks a projected response of ?dryRun=true, whose PlannedWrites
+	// were not made
+	DryRun        bool           `json:"dryRun,omitempty"`
+	PlannedWrites []PlannedWrite `json:"plannedWrites,omitzero"`
+
+	// Outcome is what the identify did (created_primary, created_secondary,
+	// merged or no_change), reported to metrics rather than to clients
+	Outcome string `json:"-"`
+}
+
+// PlannedWrite is one contact graph write a dry run would perform. Action is
+// create_contact, update_link (ContactID becomes LinkPrecedence, linked to
+// LinkedID), update_identifier (Field of ContactID changes) or record_merge
+// (primary ContactID absorbed into LinkedID). Synthetic marks a ContactID
+// standing in for a contact the request would create, whose real id is only
+// allocated on insert.
+type PlannedWrite struct {
+	Action         string `json:"action"`
+	ContactID      int64  `json:"contactId,omitempty"`
+	Synthetic      bool   `json:"synthetic,omitempty"`
+	LinkPrecedence string `json:"linkPrecedence,omitempty"`
+	LinkedID       *int64 `json:"linkedId,omitempty"`
+	Field          string `json:"field,omitempty"`
+}
+
+// JSONAPIDocument is an IdentifyResponse in the JSON:API envelope
+type JSONAPIDocument struct {
+	Data JSONAPIContact `json:"data"`
+	Meta *JSONAPIMeta   `json:"meta,omitempty"`
+}
+
+// JSONAPIContact is the primary contact as a JSON:API resource object
+type JSONAPIContact struct {
+	Type          string               `json:"type"`
+	ID            string               `json:"id"`
+	Attributes    JSONAPIAttributes    `json:"attributes"`
+	Relationships JSONAPIRelationships `json:"relationships"`
+}
+
+// JSONAPIAttributes are the consolidated identifiers of the cluster
+type JSONAPIAttributes struct {
+	Emails                 []string  `json:"emails"`
+	PhoneNumbers           []string  `json:"phoneNumbers"`
+	VerifiedEmails         []string  `json:"verifiedEmails,omitempty"`
+	VerifiedPhoneNumbers   []string  `json:"verifiedPhoneNumbers,omitempty"`
+	HistoricalEmails       []string  `json:"historicalEmails,omitzero"`
+	HistoricalPhoneNumbers []string  `json:"historicalPhoneNumbers,omitzero"`
+	ClusterCreatedAt       time.Time `json:"clusterCreatedAt"`
+	ClusterUpdatedAt       time.Time `json:"clusterUpdatedAt"`
+}
+
+// JSONAPIRelationships links the primary to its secondaries
+type JSONAPIRelationships struct {
+	Secondaries JSONAPIRelationship `json:"secondaries"`
+}
+
+// JSONAPIRelationship is a to-many relationship of resource identifiers
+type JSONAPIRelationship struct {
+	Data []JSONAPIResourceID `json:"data"`
+}
+
+// JSONAPIResourceID identifies a related resource
+type JSONAPIResourceID struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// JSONAPIMeta carries the non-resource fields of an IdentifyResponse
+type JSONAPIMeta struct {
+	Resolution      string           `json:"resolution,omitempty"`
+	NormalizedInput *NormalizedInput `json:"normalizedInput,omitempty"`
+	Partial         bool             `json:"partial,omitempty"`
+}
+
+// ContactStats summarizes the size of the contact graph for operators.
+// TotalContacts counts every row, so it is Primaries + Secondaries + Deleted.
+type ContactStats struct {
+	TotalContacts      int64 `json:"totalContacts"`
+	Primaries          int64 `json:"primaries"`
+	Secondaries        int64 `json:"secondaries"`
+	Deleted            int64 `json:"deleted"`
+	LargestClusterSize int64 `json:"largestClusterSize"`
+}
+
+// CRMRecord is a cluster flattened into one record for CRM sync
+type CRMRecord struct {
+	ID              int64     `json:"id"`
+	Email           *string   `json:"email"`
+	Phone           *string   `json:"phone"`
+	FirstSeen       time.Time `json:"firstSeen"`
+	LastSeen        time.Time `json:"lastSeen"`
+	AlternateEmails []string  `json:"alternateEmails"`
+	AlternatePhones []string  `json:"alternatePhones"`
+}
+
+// ClusterConflict is sent to the conflict resolver when a request would merge
+// existing clusters
+type ClusterConflict struct {
+	Request  IdentifyRequest   `json:"request"`
+	Clusters []ConflictCluster `json:"clusters"`
+}
+
+// ConflictCluster is one of the clusters a request would merge
+type ConflictCluster struct {
+	PrimaryContactID int64      `json:"primaryContactId"`
+	Contacts         []*Contact `json:"contacts"`
+}
+
+// ReviewItem represents an identify request held for manual review
+type ReviewItem struct {
+	ID             int64     `json:"id"`
+	Email          *string   `json:"email,omitempty"`
+	PhoneNumber    *string   `json:"phoneNumber,omitempty"`
+	EmailPrimaryID int64     `json:"emailPrimaryContactId"`
+	PhonePrimaryID int64     `json:"phonePrimaryContactId"`
+	Reason         string    `json:"reason"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// LineageEntry records a former primary absorbed into another primary
+type LineageEntry struct {
+	OldPrimaryID int64     `json:"oldPrimaryId"`
+	NewPrimaryID int64     `json:"newPrimaryId"`
+	MergedAt     time.Time `json:"mergedAt"`
+	Depth        int       `json:"depth"`
+}
+
+// LineageResponse represents the absorption history of a contact's cluster
+type LineageResponse struct {
+	ContactID        int64          `json:"contactId"`
+	PrimaryContactID int64          `json:"primaryContactId"`
+	Lineage          []LineageEntry `json:"lineage"`
+}
+
+// Bridge is an identifier whose removal would split its cluster
+type Bridge struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	// Components is the number of groups the cluster would fall apart into
+	Components int `json:"components"`
+}
+
+// BridgesResponse lists the bridging identifiers of a contact's cluster
+type BridgesResponse struct {
+	ContactID        int64    `json:"contactId"`
+	PrimaryContactID int64    `json:"primaryContactId"`
+	Bridges          []Bridge `json:"bridges"`
+}
+
+// ClusterDiagnostics is a support-facing summary of one cluster
+type ClusterDiagnostics struct {
+	ContactID            int64 `json:"contactId"`
+	PrimaryContactID     int64 `json:"primaryContactId"`
+	Size                 int   `json:"size"`
+	DeletedContacts      int   `json:"deletedContacts"`
+	DistinctEmails       int   `json:"distinctEmails"`
+	DistinctPhoneNumbers int   `json:"distinctPhoneNumbers"`
+	VerifiedIdentifiers  int   `json:"verifiedIdentifiers"`
+	// AbsorbedPrimaries counts former primaries merged into the cluster
+	AbsorbedPrimaries int       `json:"absorbedPrimaries"`
+	FirstSeen         time.Time `json:"firstSeen"`
+	LastActivity      time.Time `json:"lastActivity"`
+	AgeSeconds        int64     `json:"ageSeconds"`
+	HasBridges        bool      `json:"hasBridges"`
+	Bridges           []Bridge  `json:"bridges"`
+}
+
+// DeleteResult is the outcome of deleting one contact in a bulk delete
+type DeleteResult struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+	// PromotedID is the secondary that became primary when a primary was deleted
+	PromotedID int64 `json:"promotedContactId,omitempty"`
+	// Error explains why a contact was kept (status "conflict")
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+// ErrorResponse is the JSON body of a failed request
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail is a machine-readable error code with a human-readable message,
+// plus the X-Request-ID of the failed request
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Path is the JSON pointer of the offending value for schema violations
+	Path string `json:"path,omitempty"`
+	// ConflictingPrimaryIDs and ReviewID describe a request that could not be
+	// reconciled (409)
+	ConflictingPrimaryIDs []int64 `json:"conflictingPrimaryIds,omitempty"`
+	ReviewID              int64   `json:"reviewId,omitempty"`
+	RequestID             string  `json:"requestId,omitempty"`
+}
+
+// EmailAlias maps an alternative email address to the canonical one used for matching
+type EmailAlias struct {
+	Alias     string    `json:"alias"`
+	Canonical string    `json:"canonical"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AuditEntry is a persisted identify request body and the primary it resolved to
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	RawRequest string    `json:"rawRequest"`
+	PrimaryID  int64     `json:"primaryContactId"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// SimulationStep is one simulated identify request and its outcome
+type SimulationStep struct {
+	Request  IdentifyRequest   `json:"request"`
+	Response *IdentifyResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// SimulationResult is the outcome of replaying identify requests on a throwaway database
+type SimulationResult struct {
+	Steps    []SimulationStep  `json:"steps"`
+	Clusters []ContactResponse `json:"clusters"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestContactResponseLegacyPrimaryKey(t *testing.T) {
+	previous := LegacyPrimaryKey
+	t.Cleanup(func() { LegacyPrimaryKey = previous })
+
+	for _, legacy := range []bool{false, true} {
+		LegacyPrimaryKey = legacy
+		data, err := json.Marshal(ContactResponse{PrimaryContactID: 7})
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+		var keys map[string]any
+		if err := json.Unmarshal(data, &keys); err != nil {
+			t.Fatalf("unmarshal failed: %v", err)
+		}
+
+		if keys["primaryContactId"] != float64(7) {
+			t.Errorf("legacy=%v: primaryContactId = %v, want 7", legacy, keys["primaryContactId"])
+		}
+		got, present := keys["primaryContatctId"]
+		if present != legacy || (legacy && got != float64(7)) {
+			t.Errorf("legacy=%v: primaryContatctId = %v (present %v)", legacy, got, present)
+		}
+	}
+}
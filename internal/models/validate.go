@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on identify request identifiers, enforced before anything is stored
+const (
+	// MaxEmailLength is the longest address RFC 5321 allows (64 + "@" + 255)
+	MaxEmailLength = 320
+	// MaxPhoneNumberLength leaves room for formatting around E.164's 15 digits
+	MaxPhoneNumberLength = 32
+)
+
+// Validate checks the lengths of the request's email and phone number and
+// rejects control and other non-printable characters in them
+func (r IdentifyRequest) Validate() error {
+	if err := validateIdentifier("email", r.Email, MaxEmailLength); err != nil {
+		return err
+	}
+	return validateIdentifier("phoneNumber", r.PhoneNumber, MaxPhoneNumberLength)
+}
+
+// validateIdentifier checks one optional identifier against its limit
+func validateIdentifier(field string, value *string, maxLength int) error {
+	if value == nil {
+		return nil
+	}
+	if n := utf8.RuneCountInString(*value); n > maxLength {
+		return fmt.Errorf("%s is %d characters long, at most %d are allowed", field, n, maxLength)
+	}
+	for _, r := range *value {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return fmt.Errorf("%s contains the non-printable character %U", field, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+// Package openapi embeds the service's OpenAPI 3 spec and validates request
+// bodies against the schemas it declares. Only the schema keywords the spec
+// uses are supported: type, nullable, properties, additionalProperties,
+// maxLength, minimum (integers only) and oneOf (whose alternatives must differ
+// in type). An integer is a JSON number written without a fraction or
+// exponent, so 9.1e9 is rejected like 12.5.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+// Spec is the subset of an OpenAPI document needed for request validation
+type Spec struct {
+	Paths map[string]map[string]*Operation `json:"paths"`
+}
+
+// Operation is one method of a path
+type Operation struct {
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// RequestBody describes the accepted body of an operation
+type RequestBody struct {
+	Content map[string]*MediaType `json:"content"`
+}
+
+// MediaType holds the schema of one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema as used by OpenAPI 3.0
+type Schema struct {
+	Type                 string             `json:"type"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *int64             `json:"minimum"`
+	OneOf                []*Schema          `json:"oneOf"`
+}
+
+// ValidationError is a request the spec rejects. Path is the JSON pointer of
+// the offending value ("" for the body itself) and Status the HTTP status to
+// answer with.
+type ValidationError struct {
+	Status  int
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Load parses the embedded spec
+func Load() (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI spec: %w", err)
+	}
+	return &spec, nil
+}
+
+// Operation returns the operation declared for a path and method, if any
+func (s *Spec) Operation(path, method string) (*Operation, bool) {
+	op, ok := s.Paths[path][strings.ToLower(method)]
+	return op, ok && op != nil
+}
+
+// ValidateBody checks a request body against the operation. Empty bodies and
+// bodies that are not valid JSON are left to the handler, which reports them
+// more precisely.
+func (op *Operation) ValidateBody(contentType string, body []byte) error {
+	if op.RequestBody == nil || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	// Clients that send no Content-Type are assumed to send JSON
+	mediaType := "application/json"
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return &ValidationError{Status: http.StatusUnsupportedMediaType, Message: fmt.Sprintf("invalid Content-Type %q", contentType)}
+		}
+		mediaType = parsed
+	}
+	content, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		return &ValidationError{
+			Status:  http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("Content-Type %q is not accepted, use %s", mediaType, strings.Join(slices.Sorted(maps.Keys(op.RequestBody.Content)), " or ")),
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if content.Schema == nil || decoder.Decode(&value) != nil {
+		return nil
+	}
+	return content.Schema.validate(value, "")
+}
+
+// validate checks a decoded JSON value, reporting the first violation
+func (s *Schema) validate(value any, path string) error {
+	fail := func(format string, args ...any) error {
+		return &ValidationError{Status: http.StatusBadRequest, Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fail("must not be null")
+	}
+
+	if len(s.OneOf) > 0 {
+		types := make([]string, 0, len(s.OneOf))
+		for _, alternative := range s.OneOf {
+			if alternative.accepts(value) {
+				return alternative.validate(value, path)
+			}
+			article := "a "
+			if strings.ContainsRune("aeiou", rune(alternative.Type[0])) {
+				article = "an "
+			}
+			types = append(types, article+alternative.Type)
+		}
+		return fail("must be %s", strings.Join(types, " or "))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			property, known := s.Properties[name]
+			if !known {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return &ValidationError{Status: http.StatusBadRequest, Path: path + "/" + name, Message: "unknown property"}
+				}
+				continue
+			}
+			if err := property.validate(object[name], path+"/"+name); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if s.MaxLength != nil && utf8.RuneCountInString(str) > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+	case "integer":
+		number, ok := integer(value)
+		if !ok {
+			return fail("must be an integer")
+		}
+		if s.Minimum != nil && number.Cmp(big.NewInt(*s.Minimum)) < 0 {
+			return fail("must be at least %d", *s.Minimum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+	return nil
+}
+
+// accepts reports whether a decoded JSON value has the schema's type
+func (s *Schema) accepts(value any) bool {
+	switch s.Type {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		_, ok := integer(value)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	}
+	return true
+}
+
+// integer parses a decoded JSON number written as a plain integer, of any size
+func integer(value any) (*big.Int, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Int).SetString(number.String(), 10)
+}
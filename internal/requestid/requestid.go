@@ -0,0 +1,56 @@
+// Package requestid correlates client and server logs through the X-Request-ID
+// header: the id a client sends, or one generated for it, travels in the request
+// context and is echoed back in the response.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Header carries the request id in both directions
+const Header = "X-Request-ID"
+
+// contextKey keys the request id in a context
+type contextKey struct{}
+
+// New returns a random (version 4) UUID
+func New() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// WithID returns a copy of ctx carrying id
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request id stored in ctx, or "" when there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware stores the incoming X-Request-ID, or a new UUID when it is absent,
+// in the request context and echoes it in the response header
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if id == "" {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
+
+// Logf logs like log.Printf, tagging the line with the request id in ctx
+func Logf(ctx context.Context, format string, args ...any) {
+	log.Printf(format+" request_id=%s", append(args, FromContext(ctx))...)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// ErrInvalidAlias is returned when an alias mapping would be empty, circular or chained
+var ErrInvalidAlias = errors.New("invalid email alias")
+
+// normalize applies the static normalization, the swapped-field policy, the
+// configured per-field pipelines and the unicode policy, validates the email and
+// then resolves known email aliases
+func (s *ReconciliationService) normalize(req models.IdentifyRequest) (models.IdentifyRequest, error) {
+	req, err := s.fixSwappedFields(normalizeRequest(req))
+	if err != nil {
+		return req, err
+	}
+	req.Email = s.emailNormalizer.Normalize(req.Email)
+	req.PhoneNumber = s.phoneNormalizer.Normalize(req.PhoneNumber)
+
+	req, err = s.applyUnicodePolicy(req)
+	if err != nil || req.Email == nil {
+		return req, err
+	}
+	if !validEmail(*req.Email) {
+		return req, fmt.Errorf("%w: %q", ErrInvalidEmail, *req.Email)
+	}
+
+	canonical, err := s.resolveEmailAlias(*req.Email)
+	if err != nil {
+		return req, fmt.Errorf("failed to resolve email alias: %w", err)
+	}
+	req.Email = &canonical
+	return req, nil
+}
+
+// resolveEmailAlias returns the canonical email for an alias, or the email itself
+func (s *ReconciliationService) resolveEmailAlias(email string) (string, error) {
+	var canonical string
+	err := s.conn.QueryRow(`SELECT canonical FROM email_aliases WHERE alias = $1`, email).Scan(&canonical)
+	if errors.Is(err, sql.ErrNoRows) {
+		return email, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return canonical, nil
+}
+
+// ListEmailAliases returns every configured alias mapping
+func (s *ReconciliationService) ListEmailAliases(ctx context.Context) ([]models.EmailAlias, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	rows, err := s.conn.Query(`SELECT alias, canonical, created_at FROM email_aliases ORDER BY alias`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []models.EmailAlias{}
+	for rows.Next() {
+		var alias models.EmailAlias
+		if err := rows.Scan(&alias.Alias, &alias.Canonical, &alias.CreatedAt); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+// SetEmailAlias creates or updates the mapping alias -> canonical. Mappings are a
+// single hop, so a canonical address can't itself be an alias and vice versa.
+// Both emails are normalized as identify requests are, so the mapping matches
+// the emails it is looked up with.
+func (s *ReconciliationService) SetEmailAlias(ctx context.Context, alias, canonical string) error {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	alias, err := s.normalizeAliasEmail(alias)
+	if err != nil {
+		return err
+	}
+	canonical, err = s.normalizeAliasEmail(canonical)
+	if err != nil {
+		return err
+	}
+	if alias == canonical {
+		return fmt.Errorf("%w: alias and canonical must be different emails", ErrInvalidAlias)
+	}
+
+	var count int
+	query := `SELECT COUNT(*) FROM email_aliases WHERE alias = $1 OR canonical = $2`
+	if err := s.conn.QueryRow(query, canonical, alias).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: alias chains are not supported", ErrInvalidAlias)
+	}
+
+	upsert := `INSERT INTO email_aliases (alias, canonical, created_at) VALUES ($1, $2, $3) 
+			   ON CONFLICT (alias) DO UPDATE SET canonical = excluded.canonical`
+	_, err = s.conn.Exec(upsert, alias, canonical, time.Now())
+	return err
+}
+
+// normalizeAliasEmail runs an alias mapping's email through the normalization
+// and validation identify requests get
+func (s *ReconciliationService) normalizeAliasEmail(email string) (string, error) {
+	req := normalizeRequest(models.IdentifyRequest{Email: &email})
+	req.Email = s.emailNormalizer.Normalize(req.Email)
+	req, err := s.applyUnicodePolicy(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAlias, err)
+	}
+	if req.Email == nil {
+		return "", fmt.Errorf("%w: alias and canonical must be non-empty emails", ErrInvalidAlias)
+	}
+	if !validEmail(*req.Email) {
+		return "", fmt.Errorf("%w: %q is not a valid email", ErrInvalidAlias, *req.Email)
+	}
+	return *req.Email, nil
+}
+
+// DeleteEmailAlias removes a mapping, reporting whether it existed. The alias is
+// normalized as SetEmailAlias stored it, unless it is not a valid email.
+func (s *ReconciliationService) DeleteEmailAlias(ctx context.Context, alias string) (bool, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	if normalized, err := s.normalizeAliasEmail(alias); err == nil {
+		alias = normalized
+	}
+	result, err := s.conn.Exec(`DELETE FROM email_aliases WHERE alias = $1`, alias)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSetEmailAliasNormalizes(t *testing.T) {
+	s := newTestService(t, nil)
+	ctx := context.Background()
+	if err := s.SetEmailAlias(ctx, " Emmett.Brown@HillValley.edu ", "DOC@hillvalley.edu"); err != nil {
+		t.Fatalf("SetEmailAlias failed: %v", err)
+	}
+
+	first := identify(t, s, ptr("doc@hillvalley.edu"), ptr("111"))
+	tests := []struct {
+		email string
+		joins bool
+	}{
+		{"emmett.brown@hillvalley.edu", true},
+		{"Emmett.Brown@hillvalley.edu", true},
+		{"marty@hillvalley.edu", false},
+	}
+	for _, tt := range tests {
+		got := identify(t, s, ptr(tt.email), nil)
+		if joined := got.Contact.PrimaryContactID == first.Contact.PrimaryContactID; joined != tt.joins {
+			t.Errorf("%s joined the cluster of doc@hillvalley.edu: %v, want %v", tt.email, joined, tt.joins)
+		}
+	}
+
+	found, err := s.DeleteEmailAlias(ctx, "EMMETT.BROWN@hillvalley.edu")
+	if err != nil || !found {
+		t.Errorf("DeleteEmailAlias = %v, %v, want the normalized alias removed", found, err)
+	}
+}
+
+func TestSetEmailAliasRejectsInvalidEmails(t *testing.T) {
+	tests := []struct {
+		name             string
+		alias, canonical string
+	}{
+		{name: "empty alias", alias: " ", canonical: "doc@hillvalley.edu"},
+		{name: "invalid alias", alias: "emmett", canonical: "doc@hillvalley.edu"},
+		{name: "invalid canonical", alias: "emmett@hillvalley.edu", canonical: "doc@"},
+		{name: "same after normalizing", alias: "DOC@hillvalley.edu", canonical: "doc@hillvalley.edu"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, nil)
+			err := s.SetEmailAlias(context.Background(), tt.alias, tt.canonical)
+			if !errors.Is(err, ErrInvalidAlias) {
+				t.Errorf("SetEmailAlias(%q, %q) = %v, want ErrInvalidAlias", tt.alias, tt.canonical, err)
+			}
+		})
+	}
+}
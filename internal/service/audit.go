@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+)
+
+// auditQueueSize bounds the audit records waiting to be written; further records are dropped
+const auditQueueSize = 256
+
+// auditPruneInterval is how often entries past the retention window are deleted
+const auditPruneInterval = time.Hour
+
+// redactedValue replaces identifiers in audit entries stored with AUDIT_PII=redact
+const redactedValue = "[redacted]"
+
+// auditRecord is an identify request waiting to be persisted
+type auditRecord struct {
+	raw        []byte
+	primaryID  int64
+	receivedAt time.Time
+}
+
+// StartAuditLog starts the background writer persisting identify requests to the
+// audit_log table. Records are written asynchronously so audits never delay responses.
+func (s *ReconciliationService) StartAuditLog() {
+	s.audit = make(chan auditRecord, auditQueueSize)
+	s.auditDone = make(chan struct{})
+	go s.writeAuditRecords()
+}
+
+// FlushAuditLog stops accepting audit records and waits until the queued ones
+// are written or ctx ends, reporting how many were left unwritten. It must only
+// be called once no request can call RecordRequest anymore.
+func (s *ReconciliationService) FlushAuditLog(ctx context.Context) error {
+	if s.audit == nil {
+		return nil
+	}
+
+	close(s.audit)
+	select {
+	case <-s.auditDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d audit records left unwritten: %w", len(s.audit), ctx.Err())
+	}
+}
+
+// RecordRequest queues the raw identify body and its resulting primary for auditing.
+// It is a no-op when the audit log is disabled and drops the record when the queue is full.
+func (s *ReconciliationService) RecordRequest(raw []byte, primaryID int64) {
+	if s.audit == nil {
+		return
+	}
+
+	select {
+	case s.audit <- auditRecord{raw: raw, primaryID: primaryID, receivedAt: time.Now()}:
+	default:
+		log.Printf("Audit queue full, dropping record for primary %d", primaryID)
+	}
+}
+
+// writeAuditRecords persists queued records and prunes expired ones
+func (s *ReconciliationService) writeAuditRecords() {
+	defer close(s.auditDone)
+
+	lastPrune := time.Time{}
+	for record := range s.audit {
+		query := `INSERT INTO audit_log (raw_request, primary_id, created_at) VALUES ($1, $2, $3)`
+		if _, err := s.db.Conn.Exec(s.db.Rebind(query), s.auditPayload(record.raw), record.primaryID, record.receivedAt); err != nil {
+			log.Printf("Error writing audit record: %v", err)
+		}
+
+		if time.Since(lastPrune) >= auditPruneInterval {
+			s.pruneAuditLog()
+			lastPrune = time.Now()
+		}
+	}
+}
+
+// auditPayload applies the configured PII handling to a raw request body
+func (s *ReconciliationService) auditPayload(raw []byte) string {
+	if s.cfg.AuditPII == config.AuditPIIRaw {
+		return string(raw)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return redactedValue
+	}
+	for _, field := range []string{"email", "phoneNumber"} {
+		if value, ok := body[field]; ok && value != nil {
+			body[field] = redactedValue
+		}
+	}
+
+	redacted, err := json.Marshal(body)
+	if err != nil {
+		return redactedValue
+	}
+	return string(redacted)
+}
+
+// pruneAuditLog deletes entries older than the retention window
+func (s *ReconciliationService) pruneAuditLog() {
+	if s.cfg.AuditRetentionDays <= 0 {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -s.cfg.AuditRetentionDays)
+	result, err := s.db.Conn.Exec(s.db.Rebind(`DELETE FROM audit_log WHERE created_at < $1`), cutoff)
+	if err != nil {
+		log.Printf("Error pruning audit log: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Pruned %d audit records older than %d days", n, s.cfg.AuditRetentionDays)
+	}
+}
+
+// ListAuditEntries returns the most recent audit entries, newest first
+func (s *ReconciliationService) ListAuditEntries(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	query := `SELECT id, raw_request, primary_id, created_at FROM audit_log ORDER BY id DESC LIMIT $1`
+
+	rows, err := s.conn.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.RawRequest, &entry.PrimaryID, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
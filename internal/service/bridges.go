@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"bitespeed/internal/models"
+)
+
+// Bridges reports the identifiers that hold the contact's cluster together:
+// emails or phone numbers whose removal would split the cluster's contacts
+// into more groups that share no identifier (articulation points of the
+// contact/identifier graph). Such identifiers are candidates for a denylist.
+func (s *ReconciliationService) Bridges(ctx context.Context, id int64) (*models.BridgesResponse, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	primaryID, err := s.resolvePrimaryID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrContactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contacts, err := s.getAllLinkedContacts(primaryID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.BridgesResponse{
+		ContactID:        id,
+		PrimaryContactID: primaryID,
+		Bridges:          []models.Bridge{},
+	}
+
+	baseline := identifierComponents(contacts, "")
+	for _, identifier := range clusterIdentifiers(contacts) {
+		components := identifierComponents(contacts, identifier.key)
+		if components <= baseline {
+			continue
+		}
+		response.Bridges = append(response.Bridges, models.Bridge{
+			Type:       identifier.kind,
+			Value:      identifier.value,
+			Components: components,
+		})
+	}
+
+	// Identifiers splitting the cluster the most come first
+	sort.SliceStable(response.Bridges, func(i, j int) bool {
+		return response.Bridges[i].Components > response.Bridges[j].Components
+	})
+	return response, nil
+}
+
+// clusterIdentifier is one email or phone number of a cluster
+type clusterIdentifier struct {
+	kind, value, key string
+}
+
+// contactIdentifierKeys returns the graph keys of a contact's identifiers
+func contactIdentifierKeys(c *models.Contact) []string {
+	var keys []string
+	if c.Email != nil && *c.Email != "" {
+		keys = append(keys, identifierEmail+":"+*c.Email)
+	}
+	if c.PhoneNumber != nil && *c.PhoneNumber != "" {
+		keys = append(keys, identifierPhone+":"+*c.PhoneNumber)
+	}
+	return keys
+}
+
+// clusterIdentifiers lists the distinct identifiers of the contacts in first-seen order
+func clusterIdentifiers(contacts []*models.Contact) []clusterIdentifier {
+	seen := make(map[string]bool)
+	var identifiers []clusterIdentifier
+	for _, c := range contacts {
+		if c.Email != nil && *c.Email != "" && !seen[identifierEmail+":"+*c.Email] {
+			seen[identifierEmail+":"+*c.Email] = true
+			identifiers = append(identifiers, clusterIdentifier{identifierEmail, *c.Email, identifierEmail + ":" + *c.Email})
+		}
+		if c.PhoneNumber != nil && *c.PhoneNumber != "" && !seen[identifierPhone+":"+*c.PhoneNumber] {
+			seen[identifierPhone+":"+*c.PhoneNumber] = true
+			identifiers = append(identifiers, clusterIdentifier{identifierPhone, *c.PhoneNumber, identifierPhone + ":" + *c.PhoneNumber})
+		}
+	}
+	return identifiers
+}
+
+// identifierComponents counts the groups of contacts connected through shared
+// identifiers, ignoring the identifier with the given key
+func identifierComponents(contacts []*models.Contact, ignored string) int {
+	parent := make([]int, len(contacts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	owner := make(map[string]int)
+	for i, c := range contacts {
+		for _, key := range contactIdentifierKeys(c) {
+			if key == ignored {
+				continue
+			}
+			if j, ok := owner[key]; ok {
+				parent[find(i)] = find(j)
+			} else {
+				owner[key] = i
+			}
+		}
+	}
+
+	components := 0
+	for i := range contacts {
+		if find(i) == i {
+			components++
+		}
+	}
+	return components
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// promoteCanonicalIdentifiers stores the most frequently seen email and phone
+// number of the cluster on the primary row, so emails[0]/phoneNumbers[0] always
+// carry the most authoritative values
+func (s *ReconciliationService) promoteCanonicalIdentifiers(primaryID int64) error {
+	contacts, err := s.getAllLinkedContacts(primaryID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, swap := range canonicalSwaps(contacts, primaryID) {
+		if err := s.setIdentifier(swap.contactID, swap.column, swap.value, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// identifierSwap is one identifier change made by canonical promotion
+type identifierSwap struct {
+	contactID int64
+	column    string
+	value     *string
+}
+
+// canonicalSwaps returns the changes promoting the cluster's most common email
+// and phone number onto the primary. The primary's previous value goes to the
+// oldest secondary that held the promoted one, so no identifier disappears
+// from the cluster.
+func canonicalSwaps(contacts []*models.Contact, primaryID int64) []identifierSwap {
+	var primary *models.Contact
+	for _, c := range contacts {
+		if c.ID == primaryID {
+			primary = c
+			break
+		}
+	}
+	if primary == nil {
+		return nil
+	}
+
+	// Oldest first, so ties go to the value seen earliest
+	sortContactsByCreation(contacts)
+
+	var swaps []identifierSwap
+	for _, column := range []string{"email", "phone_number"} {
+		field := func(c *models.Contact) *string { return c.PhoneNumber }
+		if column == "email" {
+			field = func(c *models.Contact) *string { return c.Email }
+		}
+
+		promoted, previous := mostCommonValue(contacts, field(primary), field), field(primary)
+		if equalStringPtr(promoted, previous) {
+			continue
+		}
+		for _, c := range contacts {
+			if c.ID != primary.ID && equalStringPtr(field(c), promoted) {
+				swaps = append(swaps, identifierSwap{contactID: c.ID, column: column, value: previous})
+				break
+			}
+		}
+		swaps = append(swaps, identifierSwap{contactID: primary.ID, column: column, value: promoted})
+	}
+	return swaps
+}
+
+// setIdentifier stores a contact's email or phone_number column, keeping the
+// email match key in step with the email
+func (s *ReconciliationService) setIdentifier(id int64, column string, value *string, now time.Time) error {
+	if column == "email" {
+		query := `UPDATE contacts SET email = $1, email_match_key = $2, updated_at = $3 WHERE id = $4`
+		_, err := s.conn.Exec(query, value, s.storedEmailMatchKey(value), now, id)
+		return err
+	}
+	query := `UPDATE contacts SET ` + column + ` = $1, updated_at = $2 WHERE id = $3`
+	_, err := s.conn.Exec(query, value, now, id)
+	return err
+}
+
+// mostCommonValue returns the value occurring on the most contacts. The current
+// value wins ties, so the primary row is only rewritten on a strict majority.
+func mostCommonValue(contacts []*models.Contact, current *string, field func(*models.Contact) *string) *string {
+	counts := make(map[string]int)
+	var order []string
+	for _, c := range contacts {
+		value := field(c)
+		if value == nil || *value == "" {
+			continue
+		}
+		if counts[*value] == 0 {
+			order = append(order, *value)
+		}
+		counts[*value]++
+	}
+
+	best := current
+	bestCount := 0
+	if current != nil {
+		bestCount = counts[*current]
+	}
+	for _, value := range order {
+		if counts[value] > bestCount {
+			best = &value
+			bestCount = counts[value]
+		}
+	}
+	return best
+}
+
+// equalStringPtr compares two optional strings by value
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
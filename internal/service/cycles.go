@@ -0,0 +1,93 @@
+package service
+
+import (
+	"log"
+	"slices"
+
+	"bitespeed/internal/models"
+)
+
+// validateCluster looks for linked_id cycles among a loaded cluster, such as a
+// contact linked to itself or two contacts linked to each other, and logs each
+// one. Such contacts never resolve to a primary, so reconcile hands them to
+// repairCycles before anything follows their links.
+func (s *ReconciliationService) validateCluster(contacts []*models.Contact) [][]int64 {
+	linked := make(map[int64]*int64, len(contacts))
+	for _, c := range contacts {
+		linked[c.ID] = c.LinkedID
+	}
+
+	const (
+		walking = iota + 1
+		done
+	)
+	state := make(map[int64]int, len(contacts))
+	var cycles [][]int64
+	for _, c := range contacts {
+		// Follow the linked_id chain, remembering the path walked this time
+		var path []int64
+		for id := c.ID; ; {
+			if _, inCluster := linked[id]; !inCluster || state[id] == done {
+				break
+			}
+			if state[id] == walking {
+				// Reaching a contact of the current path again closes a cycle
+				at := slices.Index(path, id)
+				cycles = append(cycles, slices.Clone(path[at:]))
+				break
+			}
+			state[id] = walking
+			path = append(path, id)
+			if linked[id] == nil {
+				break
+			}
+			id = *linked[id]
+		}
+		for _, id := range path {
+			state[id] = done
+		}
+	}
+
+	for _, cycle := range cycles {
+		log.Printf("linked_id cycle through contacts %v, re-linking them to the cluster's primary", cycle)
+	}
+	return cycles
+}
+
+// repairCycles makes primary a root again and links every member of the cycles
+// straight to it, updating the loaded contacts to match
+func (s *ReconciliationService) repairCycles(contacts []*models.Contact, cycles [][]int64, primary *models.Contact) error {
+	// The primary goes first, so the members below resolve to it
+	if err := s.updateContactPrecedence(primary.ID, "primary", nil); err != nil {
+		return err
+	}
+	for _, cycle := range cycles {
+		for _, id := range cycle {
+			if id == primary.ID {
+				continue
+			}
+			if err := s.updateContactPrecedence(id, "secondary", &primary.ID); err != nil {
+				return err
+			}
+		}
+	}
+	relinkCycles(contacts, cycles, primary)
+	log.Printf("Repaired %d linked_id cycle(s) onto primary %d", len(cycles), primary.ID)
+	return nil
+}
+
+// relinkCycles applies the links repairCycles stores to the loaded contacts only
+func relinkCycles(contacts []*models.Contact, cycles [][]int64, primary *models.Contact) {
+	byID := make(map[int64]*models.Contact, len(contacts))
+	for _, c := range contacts {
+		byID[c.ID] = c
+	}
+	primary.LinkPrecedence, primary.LinkedID = "primary", nil
+	for _, cycle := range cycles {
+		for _, id := range cycle {
+			if id != primary.ID {
+				byID[id].LinkPrecedence, byID[id].LinkedID = "secondary", &primary.ID
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+)
+
+func TestIdentifyRepairsCycles(t *testing.T) {
+	type row struct {
+		id         int64
+		email      *string
+		phone      *string
+		linkedID   *int64
+		precedence string
+	}
+	tests := []struct {
+		name string
+		rows []row
+		req  models.IdentifyRequest
+		// linked_id expected afterwards for each row id, 0 for a primary
+		want map[int64]int64
+	}{
+		{
+			name: "self-linked primary",
+			rows: []row{
+				{1, ptr("doc@hillvalley.edu"), nil, ptr(int64(1)), "primary"},
+				{2, nil, ptr("121"), ptr(int64(1)), "secondary"},
+			},
+			req:  models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("1955")},
+			want: map[int64]int64{1: 0, 2: 1, 3: 1},
+		},
+		{
+			name: "two contacts linked to each other",
+			rows: []row{
+				{1, ptr("doc@hillvalley.edu"), nil, ptr(int64(2)), "primary"},
+				{2, nil, ptr("121"), ptr(int64(1)), "secondary"},
+			},
+			req:  models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("121")},
+			want: map[int64]int64{1: 0, 2: 1},
+		},
+		{
+			name: "cycle of secondaries joining a healthy cluster",
+			rows: []row{
+				{1, ptr("doc@hillvalley.edu"), nil, nil, "primary"},
+				{2, nil, ptr("121"), ptr(int64(3)), "secondary"},
+				{3, ptr("emmett@hillvalley.edu"), nil, ptr(int64(2)), "secondary"},
+			},
+			req:  models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("121")},
+			want: map[int64]int64{1: 0, 2: 1, 3: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A regression loops until the timeout instead of hanging the test run
+			s := newTestService(t, func(cfg *config.Config) { cfg.DBTimeout = 5 * time.Second })
+			now := time.Now()
+			for _, r := range tt.rows {
+				insertRow(t, s, r.id, r.email, r.phone, r.linkedID, r.precedence, now.Add(time.Duration(r.id)*time.Minute))
+			}
+
+			response, err := s.Identify(context.Background(), tt.req, IdentifyOptions{})
+			if err != nil {
+				t.Fatalf("identify failed: %v", err)
+			}
+			if response.Contact.PrimaryContactID != 1 {
+				t.Errorf("primaryContactId = %d, want 1", response.Contact.PrimaryContactID)
+			}
+
+			for id, want := range tt.want {
+				var linkedID sql.NullInt64
+				var precedence string
+				if err := s.conn.QueryRow(`SELECT linked_id, link_precedence FROM contacts WHERE id = $1`, id).Scan(&linkedID, &precedence); err != nil {
+					t.Fatalf("failed to read contact %d: %v", id, err)
+				}
+				wantPrecedence := "secondary"
+				if want == 0 {
+					wantPrecedence = "primary"
+				}
+				if linkedID.Int64 != want || precedence != wantPrecedence {
+					t.Errorf("contact %d is %s linked to %d, want %s linked to %d", id, precedence, linkedID.Int64, wantPrecedence, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCluster(t *testing.T) {
+	contacts := []*models.Contact{
+		{ID: 1, LinkPrecedence: "primary"},
+		{ID: 2, LinkedID: ptr(int64(1)), LinkPrecedence: "secondary"},
+		{ID: 3, LinkedID: ptr(int64(3)), LinkPrecedence: "secondary"},
+		{ID: 4, LinkedID: ptr(int64(5)), LinkPrecedence: "secondary"},
+		{ID: 5, LinkedID: ptr(int64(4)), LinkPrecedence: "secondary"},
+	}
+	s := newTestService(t, nil)
+
+	cycles := s.validateCluster(contacts)
+	if len(cycles) != 2 || len(cycles[0]) != 1 || cycles[0][0] != 3 || len(cycles[1]) != 2 {
+		t.Errorf("cycles = %v, want [[3] [4 5]]", cycles)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// MaxBulkDelete caps the ids accepted by a single DeleteContacts call
+const MaxBulkDelete = 100
+
+// Per-id outcomes reported by DeleteContacts
+const (
+	DeleteStatusDeleted  = "deleted"
+	DeleteStatusNotFound = "not_found"
+	DeleteStatusConflict = "conflict"
+)
+
+// ErrTooManyIDs is returned when a bulk request exceeds MaxBulkDelete ids
+var ErrTooManyIDs = fmt.Errorf("at most %d ids may be deleted at once", MaxBulkDelete)
+
+// DeleteContacts deletes the contacts in one transaction, in the order given:
+// soft deletes stamp deleted_at, while SOFT_DELETE=false removes the rows. When a primary is deleted, its oldest active secondary is promoted and
+// the other dependents are re-linked to it, so the rest of the cluster stays one
+// identity. A secondary other active contacts still link to is kept and
+// reported with status "conflict", as DeleteContact refuses it.
+func (s *ReconciliationService) DeleteContacts(ctx context.Context, ids []int64) ([]models.DeleteResult, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	if len(ids) > MaxBulkDelete {
+		return nil, ErrTooManyIDs
+	}
+
+	tx, err := s.db.Conn.BeginTx(s.ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	results := make([]models.DeleteResult, 0, len(ids))
+	for _, id := range ids {
+		result, err := deleteContact(s.withConn(tx).conn, id, now, s.cfg.SoftDelete)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete contact %d: %w", id, err)
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit deletes: %w", err)
+	}
+
+	// Primaries may have changed
+	s.matches.resetPositive()
+	return results, nil
+}
+
+// DeleteContact deletes one active contact like DeleteContacts. Unknown and
+// already deleted ids return ErrContactNotFound; a secondary other contacts
+// still link to is refused with a ConflictError instead of orphaning them.
+func (s *ReconciliationService) DeleteContact(ctx context.Context, id int64) (models.DeleteResult, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+
+	tx, err := s.db.Conn.BeginTx(s.ctx, nil)
+	if err != nil {
+		return models.DeleteResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	s = s.withConn(tx)
+
+	result, err := deleteContact(s.conn, id, time.Now(), s.cfg.SoftDelete)
+	if err != nil {
+		return models.DeleteResult{}, fmt.Errorf("failed to delete contact %d: %w", id, err)
+	}
+	switch result.Status {
+	case DeleteStatusNotFound:
+		return models.DeleteResult{}, ErrContactNotFound
+	case DeleteStatusConflict:
+		return models.DeleteResult{}, &ConflictError{
+			Code:       result.Error.Code,
+			Message:    result.Error.Message,
+			PrimaryIDs: result.Error.ConflictingPrimaryIDs,
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return models.DeleteResult{}, fmt.Errorf("failed to commit delete: %w", err)
+	}
+
+	s.matches.resetPositive()
+	return result, nil
+}
+
+// deleteContact deletes one active contact, handing a deleted primary's cluster
+// over to its oldest active secondary first. A secondary other active contacts
+// still link to is left in place with status "conflict" instead of orphaning
+// them. With soft set the row keeps a deleted_at stamp, otherwise it is removed
+// once nothing links to it.
+func deleteContact(tx querier, id int64, now time.Time, soft bool) (models.DeleteResult, error) {
+	result := models.DeleteResult{ID: id, Status: DeleteStatusNotFound}
+
+	var precedence string
+	var linkedID sql.NullInt64
+	err := tx.QueryRow(`SELECT link_precedence, linked_id FROM contacts WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&precedence, &linkedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if precedence != "primary" {
+		var dependents int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM contacts WHERE linked_id = $1 AND deleted_at IS NULL`, id).Scan(&dependents); err != nil {
+			return result, fmt.Errorf("failed to count dependents: %w", err)
+		}
+		if dependents > 0 {
+			result.Status = DeleteStatusConflict
+			result.Error = &models.ErrorDetail{
+				Code:                  ConflictOrphanedDependents,
+				Message:               fmt.Sprintf("%d active contacts are linked to secondary %d", dependents, id),
+				ConflictingPrimaryIDs: []int64{linkedID.Int64},
+			}
+			return result, nil
+		}
+	}
+
+	if precedence == "primary" {
+		if result.PromotedID, err = promoteSuccessor(tx, id, now); err != nil {
+			return result, err
+		}
+	}
+
+	if soft {
+		_, err = tx.Exec(`UPDATE contacts SET deleted_at = $1, updated_at = $2 WHERE id = $3`, now, now, id)
+	} else {
+		err = hardDeleteContact(tx, id, now)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Status = DeleteStatusDeleted
+
+	if result.PromotedID != 0 {
+		log.Printf("Deleted primary %d, promoted %d", id, result.PromotedID)
+	}
+	return result, nil
+}
+
+// promoteSuccessor makes the oldest active secondary of a primary being deleted
+// the primary of the rest of its cluster, returning its id (0 when the primary
+// has no active secondaries)
+func promoteSuccessor(tx querier, id int64, now time.Time) (int64, error) {
+	var successorID int64
+	query := `SELECT id FROM contacts WHERE linked_id = $1 AND deleted_at IS NULL ORDER BY created_at, id LIMIT 1`
+	err := tx.QueryRow(query, id).Scan(&successorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	promote := `UPDATE contacts SET link_precedence = 'primary', linked_id = NULL, updated_at = $1 WHERE id = $2`
+	if _, err := tx.Exec(promote, now, successorID); err != nil {
+		return 0, err
+	}
+	relink := `UPDATE contacts SET linked_id = $1, updated_at = $2 WHERE linked_id = $3 AND id <> $4`
+	if _, err := tx.Exec(relink, successorID, now, id, successorID); err != nil {
+		return 0, err
+	}
+	return successorID, nil
+}
+
+// hardDeleteContact removes a contact's row. Only soft-deleted contacts from
+// before SOFT_DELETE was turned off can still link to it; they are unlinked,
+// as a restore would make them primaries of their own anyway, so linked_id
+// never points at a missing row.
+func hardDeleteContact(tx querier, id int64, now time.Time) error {
+	if _, err := tx.Exec(`UPDATE contacts SET linked_id = NULL, updated_at = $1 WHERE linked_id = $2`, now, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM contacts WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+)
+
+// seedDeletable stores primary 1 with secondary 2, which contact 3 still links
+// to, and primary 4 with secondary 5
+func seedDeletable(t *testing.T, s *ReconciliationService) {
+	t.Helper()
+	now := time.Now()
+	insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("111"), nil, "primary", now)
+	insertRow(t, s, 2, ptr("emmett@hillvalley.edu"), ptr("111"), ptr(int64(1)), "secondary", now.Add(time.Second))
+	insertRow(t, s, 3, ptr("brown@hillvalley.edu"), nil, ptr(int64(2)), "secondary", now.Add(2*time.Second))
+	insertRow(t, s, 4, ptr("marty@hillvalley.edu"), ptr("222"), nil, "primary", now)
+	insertRow(t, s, 5, ptr("mcfly@hillvalley.edu"), ptr("222"), ptr(int64(4)), "secondary", now.Add(time.Second))
+}
+
+func TestDeleteContacts(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []int64
+		// want is the status and promoted contact expected per id
+		want []models.DeleteResult
+	}{
+		{
+			name: "mixed",
+			ids:  []int64{2, 99, 4},
+			want: []models.DeleteResult{
+				{ID: 2, Status: DeleteStatusConflict},
+				{ID: 99, Status: DeleteStatusNotFound},
+				{ID: 4, Status: DeleteStatusDeleted, PromotedID: 5},
+			},
+		},
+		{
+			name: "dependent deleted first",
+			ids:  []int64{3, 2},
+			want: []models.DeleteResult{
+				{ID: 3, Status: DeleteStatusDeleted},
+				{ID: 2, Status: DeleteStatusDeleted},
+			},
+		},
+		{
+			name: "already deleted",
+			ids:  []int64{5, 5},
+			want: []models.DeleteResult{
+				{ID: 5, Status: DeleteStatusDeleted},
+				{ID: 5, Status: DeleteStatusNotFound},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, nil)
+			seedDeletable(t, s)
+
+			results, err := s.DeleteContacts(context.Background(), tt.ids)
+			if err != nil {
+				t.Fatalf("DeleteContacts failed: %v", err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.want))
+			}
+			for i, got := range results {
+				want := tt.want[i]
+				if got.ID != want.ID || got.Status != want.Status || got.PromotedID != want.PromotedID {
+					t.Errorf("result %d = %+v, want %+v", i, got, want)
+				}
+				if (got.Status == DeleteStatusConflict) != (got.Error != nil) {
+					t.Errorf("result %d: status %s with error %+v", i, got.Status, got.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteContactsKeepsSecondaryWithDependents(t *testing.T) {
+	s := newTestService(t, nil)
+	seedDeletable(t, s)
+
+	results, err := s.DeleteContacts(context.Background(), []int64{2})
+	if err != nil {
+		t.Fatalf("DeleteContacts failed: %v", err)
+	}
+	failure := results[0].Error
+	if failure == nil || failure.Code != ConflictOrphanedDependents || !slices.Equal(failure.ConflictingPrimaryIDs, []int64{1}) {
+		t.Fatalf("error = %+v, want %s with primary 1", failure, ConflictOrphanedDependents)
+	}
+
+	// The single delete refuses the same contact
+	_, err = s.DeleteContact(context.Background(), 2)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.Code != ConflictOrphanedDependents {
+		t.Fatalf("DeleteContact error = %v, want %s", err, ConflictOrphanedDependents)
+	}
+
+	// Contact 3 still reaches its primary through 2
+	response := identify(t, s, ptr("brown@hillvalley.edu"), nil)
+	if response.Contact.PrimaryContactID != 1 {
+		t.Errorf("primaryContactId = %d, want 1", response.Contact.PrimaryContactID)
+	}
+}
+
+func TestHardDeleteContacts(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []int64
+		want []models.DeleteResult
+		// stored lists the ids left in the table
+		stored []int64
+	}{
+		{
+			name:   "secondary",
+			ids:    []int64{5},
+			want:   []models.DeleteResult{{ID: 5, Status: DeleteStatusDeleted}},
+			stored: []int64{1, 2, 3, 4, 6},
+		},
+		{
+			name:   "primary with dependents",
+			ids:    []int64{1},
+			want:   []models.DeleteResult{{ID: 1, Status: DeleteStatusDeleted, PromotedID: 2}},
+			stored: []int64{2, 3, 4, 5, 6},
+		},
+		{
+			name:   "secondary with dependents",
+			ids:    []int64{2},
+			want:   []models.DeleteResult{{ID: 2, Status: DeleteStatusConflict}},
+			stored: []int64{1, 2, 3, 4, 5, 6},
+		},
+		{
+			name: "primary with only a soft-deleted dependent",
+			ids:  []int64{5, 4},
+			want: []models.DeleteResult{
+				{ID: 5, Status: DeleteStatusDeleted},
+				{ID: 4, Status: DeleteStatusDeleted},
+			},
+			stored: []int64{1, 2, 3, 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, func(cfg *config.Config) { cfg.SoftDelete = false })
+			seedDeletable(t, s)
+			// Soft-deleted before SOFT_DELETE was turned off
+			insertRow(t, s, 6, ptr("biff@hillvalley.edu"), ptr("222"), ptr(int64(4)), "secondary", time.Now().Add(2*time.Second))
+			if _, err := s.conn.Exec(`UPDATE contacts SET deleted_at = $1 WHERE id = 6`, time.Now()); err != nil {
+				t.Fatalf("failed to delete contact 6: %v", err)
+			}
+
+			results, err := s.DeleteContacts(context.Background(), tt.ids)
+			if err != nil {
+				t.Fatalf("DeleteContacts failed: %v", err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.want))
+			}
+			for i, got := range results {
+				want := tt.want[i]
+				if got.ID != want.ID || got.Status != want.Status || got.PromotedID != want.PromotedID {
+					t.Errorf("result %d = %+v, want %+v", i, got, want)
+				}
+			}
+
+			var stored []int64
+			rows, err := s.conn.Query(`SELECT id FROM contacts ORDER BY id`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					t.Fatal(err)
+				}
+				stored = append(stored, id)
+			}
+			if !slices.Equal(stored, tt.stored) {
+				t.Errorf("stored ids = %v, want %v", stored, tt.stored)
+			}
+
+			var dangling int
+			query := `SELECT COUNT(*) FROM contacts c WHERE c.linked_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM contacts p WHERE p.id = c.linked_id)`
+			if err := s.conn.QueryRow(query).Scan(&dangling); err != nil {
+				t.Fatal(err)
+			}
+			if dangling != 0 {
+				t.Errorf("%d contacts link to a removed row", dangling)
+			}
+		})
+	}
+}
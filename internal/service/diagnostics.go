@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// Diagnostics summarizes the cluster containing a contact for support: its size,
+// identifier counts, age and last activity, absorbed primaries, verified
+// identifiers and bridging identifiers
+func (s *ReconciliationService) Diagnostics(ctx context.Context, id int64) (*models.ClusterDiagnostics, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	bridges, err := s.Bridges(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	primaryID := bridges.PrimaryContactID
+
+	contacts, err := s.getAllLinkedContacts(primaryID)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.getDeletedLinkedContacts(primaryID)
+	if err != nil {
+		return nil, err
+	}
+	lineage, err := s.Lineage(ctx, primaryID)
+	if err != nil {
+		return nil, err
+	}
+	verified, err := s.clusterVerification(primaryID)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := &models.ClusterDiagnostics{
+		ContactID:           id,
+		PrimaryContactID:    primaryID,
+		Size:                len(contacts),
+		DeletedContacts:     len(deleted),
+		AbsorbedPrimaries:   len(lineage.Lineage),
+		VerifiedIdentifiers: len(verified),
+		Bridges:             bridges.Bridges,
+		HasBridges:          len(bridges.Bridges) > 0,
+	}
+	for _, identifier := range clusterIdentifiers(contacts) {
+		if identifier.kind == identifierEmail {
+			diagnostics.DistinctEmails++
+		} else {
+			diagnostics.DistinctPhoneNumbers++
+		}
+	}
+
+	diagnostics.FirstSeen, diagnostics.LastActivity = clusterTimestamps(contacts)
+	if !diagnostics.FirstSeen.IsZero() {
+		diagnostics.AgeSeconds = int64(time.Since(diagnostics.FirstSeen).Seconds())
+	}
+	return diagnostics, nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+)
+
+// Planned write actions reported by dry runs
+const (
+	WriteCreateContact    = "create_contact"
+	WriteUpdateLink       = "update_link"
+	WriteUpdateIdentifier = "update_identifier"
+	WriteRecordMerge      = "record_merge"
+)
+
+// projectIdentify answers a dry run: it reads the contacts the request matches
+// and applies the reconciliation to them in memory. Nothing is written, no
+// transaction is opened and no id is allocated, so the projection takes no
+// locks and leaves the database, its sequences, the match cache and the
+// velocity counters as they were. The conflict resolver is not consulted;
+// clusters it would be asked about are projected as merged. Contacts the
+// request would create get synthetic negative ids.
+func (s *ReconciliationService) projectIdentify(req models.IdentifyRequest, opts IdentifyOptions) (*models.IdentifyResponse, reconcileResult, error) {
+	bound := *s
+	bound.dryRun = true
+	s = &bound
+	plan := &writePlan{writes: []models.PlannedWrite{}, now: time.Now()}
+
+	if s.cfg.ExactMatchFastPath {
+		primary, err := s.findExactPrimary(req)
+		if err != nil {
+			return nil, reconcileResult{}, fmt.Errorf("failed to look up exact match: %w", err)
+		}
+		if primary != nil {
+			cluster, err := s.getAllLinkedContacts(primary.ID)
+			if err != nil {
+				return nil, reconcileResult{}, err
+			}
+			response, err := s.projectResponse(primary.ID, cluster, plan, req, opts)
+			return response, reconcileResult{primaryID: primary.ID, action: ActionNoChange, confidence: 1}, err
+		}
+	}
+
+	linkedContacts, err := s.matchCluster(req, opts, false)
+	if err != nil {
+		return nil, reconcileResult{}, err
+	}
+
+	if len(linkedContacts) == 0 {
+		primary := plan.create(req, nil, "primary")
+		response, err := s.projectResponse(primary.ID, []*models.Contact{primary}, plan, req, opts)
+		return response, reconcileResult{primaryID: primary.ID, action: ActionCreatedPrimary}, err
+	}
+
+	if s.cfg.ConflictPolicy == config.ConflictPolicyFlag {
+		conflict, err := s.clusterConflict(req)
+		if err != nil {
+			return nil, reconcileResult{}, err
+		}
+		if conflict != nil {
+			return nil, reconcileResult{}, conflict
+		}
+	}
+
+	primary, err := s.selectPrimaryContact(linkedContacts, opts.PrimaryStrategy, req.AccountID)
+	if err != nil {
+		return nil, reconcileResult{}, fmt.Errorf("failed to select primary contact: %w", err)
+	}
+	result := reconcileResult{
+		primaryID:  primary.ID,
+		action:     ActionNoChange,
+		confidence: knownIdentifierShare(linkedContacts, req),
+	}
+
+	cluster := linkedContacts
+	if s.hasNewInformation(linkedContacts, req.Email, req.PhoneNumber) {
+		cluster = append(cluster, plan.create(req, &primary.ID, "secondary"))
+		result.action = ActionCreatedSecondary
+	}
+	if plan.relink(linkedContacts, primary.ID) {
+		result.action = ActionMerged
+	}
+	if s.cfg.CanonicalPromotion {
+		plan.promote(cluster, primary.ID)
+	}
+
+	response, err := s.projectResponse(primary.ID, cluster, plan, req, opts)
+	return response, result, err
+}
+
+// projectResponse builds the dry run's response from the projected cluster
+func (s *ReconciliationService) projectResponse(primaryID int64, cluster []*models.Contact, plan *writePlan, req models.IdentifyRequest, opts IdentifyOptions) (*models.IdentifyResponse, error) {
+	verified, err := s.identifierVerification(cluster, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load verified identifiers: %w", err)
+	}
+	response, err := s.assembleResponse(primaryID, cluster, verified, opts)
+	if err != nil {
+		return nil, err
+	}
+	applyRequestExtras(response, req, opts, s.cfg.EmptyIdentifierPlaceholder)
+	response.DryRun = true
+	response.PlannedWrites = plan.writes
+	return response, nil
+}
+
+// writePlan collects the writes of a dry run while it changes the loaded
+// contacts the way those writes would change the stored ones
+type writePlan struct {
+	writes []models.PlannedWrite
+	now    time.Time
+	// lastID is the last synthetic id handed out, counting down from -1
+	lastID int64
+}
+
+// create plans inserting the request as a new contact
+func (p *writePlan) create(req models.IdentifyRequest, linkedID *int64, precedence string) *models.Contact {
+	p.lastID--
+	p.writes = append(p.writes, models.PlannedWrite{
+		Action:         WriteCreateContact,
+		ContactID:      p.lastID,
+		Synthetic:      true,
+		LinkPrecedence: precedence,
+		LinkedID:       linkedID,
+	})
+	return &models.Contact{
+		ID:             p.lastID,
+		PhoneNumber:    req.PhoneNumber,
+		Email:          req.Email,
+		AccountID:      req.AccountID,
+		LinkedID:       linkedID,
+		LinkPrecedence: precedence,
+		CreatedAt:      p.now,
+		UpdatedAt:      p.now,
+	}
+}
+
+// relink plans what reconcilePrimaryStatus writes: the primary is promoted and
+// every other contact becomes its direct secondary. It reports whether another
+// primary was demoted.
+func (p *writePlan) relink(contacts []*models.Contact, primaryID int64) bool {
+	for _, c := range contacts {
+		if c.ID == primaryID && c.LinkPrecedence != "primary" {
+			p.setLink(c, "primary", nil)
+		}
+	}
+
+	merged := false
+	for _, c := range contacts {
+		if c.ID == primaryID || (c.LinkPrecedence == "secondary" && c.LinkedID != nil && *c.LinkedID == primaryID) {
+			continue
+		}
+		demoted := c.LinkPrecedence == "primary"
+		p.setLink(c, "secondary", &primaryID)
+		if demoted {
+			p.writes = append(p.writes, models.PlannedWrite{Action: WriteRecordMerge, ContactID: c.ID, LinkedID: &primaryID})
+			merged = true
+		}
+	}
+	return merged
+}
+
+// setLink plans an update of a contact's link_precedence and linked_id
+func (p *writePlan) setLink(c *models.Contact, precedence string, linkedID *int64) {
+	c.LinkPrecedence, c.LinkedID, c.UpdatedAt = precedence, linkedID, p.now
+	p.writes = append(p.writes, models.PlannedWrite{
+		Action:         WriteUpdateLink,
+		ContactID:      c.ID,
+		Synthetic:      c.ID < 0,
+		LinkPrecedence: precedence,
+		LinkedID:       linkedID,
+	})
+}
+
+// promote plans the identifier swaps of canonical promotion
+func (p *writePlan) promote(cluster []*models.Contact, primaryID int64) {
+	for _, swap := range canonicalSwaps(cluster, primaryID) {
+		for _, c := range cluster {
+			if c.ID != swap.contactID {
+				continue
+			}
+			if swap.column == "email" {
+				c.Email = swap.value
+			} else {
+				c.PhoneNumber = swap.value
+			}
+			c.UpdatedAt = p.now
+		}
+		p.writes = append(p.writes, models.PlannedWrite{
+			Action:    WriteUpdateIdentifier,
+			ContactID: swap.contactID,
+			Synthetic: swap.contactID < 0,
+			Field:     swap.column,
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+)
+
+// snapshot renders every stored contact, merge record and review entry, so a
+// test can check a dry run left the database as it found it
+func snapshot(t *testing.T, s *ReconciliationService) string {
+	t.Helper()
+	var b strings.Builder
+	for _, query := range []string{
+		`SELECT id, COALESCE(email, ''), COALESCE(phone_number, ''), COALESCE(linked_id, 0), link_precedence, updated_at FROM contacts ORDER BY id`,
+		`SELECT old_primary_id, new_primary_id, 0, 0, '', '' FROM merged_into ORDER BY old_primary_id`,
+		`SELECT id, 0, 0, 0, status, '' FROM review_queue ORDER BY id`,
+	} {
+		rows, err := s.conn.Query(query)
+		if err != nil {
+			t.Fatalf("failed to snapshot: %v", err)
+		}
+		for rows.Next() {
+			var id, email, phone, linkedID, precedence, updatedAt any
+			if err := rows.Scan(&id, &email, &phone, &linkedID, &precedence, &updatedAt); err != nil {
+				rows.Close()
+				t.Fatalf("failed to snapshot: %v", err)
+			}
+			fmt.Fprintln(&b, id, email, phone, linkedID, precedence, updatedAt)
+		}
+		rows.Close()
+		b.WriteString("--\n")
+	}
+	return b.String()
+}
+
+func TestIdentifyDryRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		seed         func(t *testing.T, s *ReconciliationService)
+		req          models.IdentifyRequest
+		primaryID    int64
+		secondaryIDs []int64
+		resolution   string
+		writes       []string
+	}{
+		{
+			name:       "new primary",
+			req:        models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("111")},
+			primaryID:  -1,
+			resolution: ActionCreatedPrimary,
+			writes:     []string{"create_contact -1 synthetic primary"},
+		},
+		{
+			name: "new secondary",
+			seed: func(t *testing.T, s *ReconciliationService) {
+				insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("111"), nil, "primary", time.Now())
+			},
+			req:          models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("222")},
+			primaryID:    1,
+			secondaryIDs: []int64{-1},
+			resolution:   ActionCreatedSecondary,
+			writes:       []string{"create_contact -1 synthetic secondary 1"},
+		},
+		{
+			name:         "merge",
+			seed:         seedRivalPrimaries,
+			req:          models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("222")},
+			primaryID:    1,
+			secondaryIDs: []int64{2},
+			resolution:   ActionMerged,
+			writes:       []string{"update_link 2 secondary 1", "record_merge 2 1"},
+		},
+		{
+			name: "no change",
+			seed: func(t *testing.T, s *ReconciliationService) {
+				insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("111"), nil, "primary", time.Now())
+			},
+			req:        models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("111")},
+			primaryID:  1,
+			resolution: ActionNoChange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, nil)
+			if tt.seed != nil {
+				tt.seed(t, s)
+			}
+			// A dry run projects a merge without asking the resolver
+			s.SetConflictResolver(ConflictResolverFunc(func(ctx context.Context, conflict models.ClusterConflict) (ConflictDecision, error) {
+				t.Error("dry run consulted the conflict resolver")
+				return DecisionKeepSeparate, nil
+			}), time.Second, DecisionMerge)
+			before := snapshot(t, s)
+
+			response, err := s.Identify(context.Background(), tt.req, IdentifyOptions{DryRun: true, Verbose: true})
+			if err != nil {
+				t.Fatalf("dry run failed: %v", err)
+			}
+
+			if !response.DryRun || response.Resolution != tt.resolution {
+				t.Errorf("dryRun = %v, resolution = %q, want true, %q", response.DryRun, response.Resolution, tt.resolution)
+			}
+			if response.Contact.PrimaryContactID != tt.primaryID {
+				t.Errorf("primaryContactId = %d, want %d", response.Contact.PrimaryContactID, tt.primaryID)
+			}
+			if !slices.Equal(response.Contact.SecondaryContactIDs, tt.secondaryIDs) {
+				t.Errorf("secondaryContactIds = %v, want %v", response.Contact.SecondaryContactIDs, tt.secondaryIDs)
+			}
+
+			var writes []string
+			for _, w := range response.PlannedWrites {
+				parts := []string{w.Action, fmt.Sprint(w.ContactID)}
+				if w.Synthetic {
+					parts = append(parts, "synthetic")
+				}
+				if w.LinkPrecedence != "" {
+					parts = append(parts, w.LinkPrecedence)
+				}
+				if w.LinkedID != nil {
+					parts = append(parts, fmt.Sprint(*w.LinkedID))
+				}
+				writes = append(writes, strings.Join(parts, " "))
+			}
+			if !slices.Equal(writes, tt.writes) {
+				t.Errorf("plannedWrites = %q, want %q", writes, tt.writes)
+			}
+
+			if after := snapshot(t, s); after != before {
+				t.Errorf("dry run changed the database:\nbefore:\n%safter:\n%s", before, after)
+			}
+		})
+	}
+}
+
+func TestIdentifyDryRunConflict(t *testing.T) {
+	s := newTestService(t, func(cfg *config.Config) { cfg.ConflictPolicy = config.ConflictPolicyFlag })
+	now := time.Now()
+	insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("111"), nil, "primary", now)
+	insertRow(t, s, 2, ptr("emmett@hillvalley.edu"), nil, ptr(int64(1)), "secondary", now)
+	insertRow(t, s, 3, ptr("marty@hillvalley.edu"), ptr("222"), nil, "primary", now)
+	insertRow(t, s, 4, nil, ptr("333"), ptr(int64(3)), "secondary", now)
+	before := snapshot(t, s)
+
+	_, err := s.Identify(context.Background(), models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("222")}, IdentifyOptions{DryRun: true})
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("dry run error = %v, want a conflict", err)
+	}
+	if conflictErr.ReviewID != 0 {
+		t.Errorf("reviewId = %d, want none", conflictErr.ReviewID)
+	}
+	if after := snapshot(t, s); after != before {
+		t.Errorf("dry run changed the database:\nbefore:\n%safter:\n%s", before, after)
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrContactNotFound is returned when a contact id does not exist
+var ErrContactNotFound = errors.New("contact not found")
+
+// errMultiplePrimaries is returned when the request's contacts still resolve to
+// several primaries after reconciling; Identify retries it in a new transaction
+var errMultiplePrimaries = errors.New("request contacts resolve to multiple primaries")
+
+// Conflict codes reported in ConflictError
+const (
+	// ConflictReviewRequired: the request would merge two established, unrelated
+	// clusters and was held for manual review (CONFLICT_POLICY=flag)
+	ConflictReviewRequired = "REVIEW_REQUIRED"
+
+	// ConflictConfusableEmail: the email mixes Latin letters with lookalikes from
+	// other scripts and EMAIL_UNICODE_POLICY=flag rejects it
+	ConflictConfusableEmail = "CONFUSABLE_EMAIL"
+
+	// ConflictOrphanedDependents: deleting the secondary would leave contacts
+	// linked to it without a path to their primary
+	ConflictOrphanedDependents = "ORPHANED_DEPENDENTS"
+)
+
+// ConflictError is returned when the service cannot reconcile a request on its
+// own. Handlers map it to HTTP 409 with the conflicting primaries.
+type ConflictError struct {
+	Code       string
+	Message    string
+	PrimaryIDs []int64
+	// ReviewID is set when the request was stored in the review queue
+	ReviewID int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s (primaries %v)", e.Code, e.Message, e.PrimaryIDs)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"bitespeed/internal/models"
+)
+
+// exportPageSize is how many contacts an export reads per query
+const exportPageSize = 500
+
+// ExportContacts calls fn for every active contact in id order. Contacts are
+// read in keyset pages and each page is closed before fn sees it, so the
+// connection is released between pages and a slow consumer never holds it.
+// The queries are bound to ctx only: an export takes as long as the table is
+// large, so DB_TIMEOUT_MS does not apply. An error from fn stops the export and
+// is returned.
+func (s *ReconciliationService) ExportContacts(ctx context.Context, fn func(*models.Contact) error) error {
+	conn := reboundConn{conn: s.db.Conn, db: s.db, ctx: ctx}
+	var lastID int64
+	for {
+		page, err := s.exportPage(conn, lastID)
+		if err != nil {
+			return err
+		}
+		for _, c := range page {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		if len(page) < exportPageSize {
+			return nil
+		}
+		lastID = page[len(page)-1].ID
+	}
+}
+
+// exportPage reads the next page of active contacts after lastID
+func (s *ReconciliationService) exportPage(conn reboundConn, lastID int64) ([]*models.Contact, error) {
+	query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at
+			  FROM contacts WHERE deleted_at IS NULL AND id > $1 ORDER BY id LIMIT $2`
+	rows, err := conn.Query(query, lastID, exportPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %w", err)
+	}
+	defer rows.Close()
+
+	var page []*models.Contact
+	for rows.Next() {
+		c, err := scanContact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		page = append(page, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read contacts: %w", err)
+	}
+	return page, nil
+}
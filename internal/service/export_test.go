@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+func TestExportContacts(t *testing.T) {
+	s := newTestService(t, nil)
+	now := time.Now()
+	total := exportPageSize + 2
+	for id := int64(1); id <= int64(total); id++ {
+		insertRow(t, s, id, nil, ptr("555"), nil, "primary", now)
+	}
+	if _, err := s.conn.Exec(`UPDATE contacts SET deleted_at = $1 WHERE id = $2`, now, 7); err != nil {
+		t.Fatalf("failed to delete contact 7: %v", err)
+	}
+
+	var ids []int64
+	err := s.ExportContacts(context.Background(), func(c *models.Contact) error {
+		ids = append(ids, c.ID)
+		// The connection is free while a page is consumed, even on SQLite's
+		// single connection
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var count int
+		return s.db.Conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM contacts`).Scan(&count)
+	})
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	if len(ids) != total-1 {
+		t.Fatalf("exported %d contacts, want %d", len(ids), total-1)
+	}
+	for i, id := range ids {
+		if id == 7 {
+			t.Errorf("deleted contact 7 was exported")
+		}
+		if i > 0 && id <= ids[i-1] {
+			t.Fatalf("contacts out of order: %d after %d", id, ids[i-1])
+		}
+	}
+	if last := ids[len(ids)-1]; last != int64(total) {
+		t.Errorf("last exported contact = %d, want %d", last, total)
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+)
+
+// confusables maps Cyrillic and Greek letters to the Latin letters they render
+// like, covering the lookalikes commonly used to spoof email addresses
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j',
+	'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'т': 't',
+	'ս': 'u', 'ѵ': 'v', 'ԝ': 'w', 'х': 'x', 'у': 'y', 'ӏ': 'l',
+	// Greek
+	'α': 'a', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't',
+	'υ': 'u', 'χ': 'x',
+}
+
+// applyUnicodePolicy NFKC-normalizes the email and handles addresses mixing Latin
+// letters with lookalikes from other scripts according to EMAIL_UNICODE_POLICY:
+// "collapse" replaces the lookalikes with their Latin letters, "flag" rejects the
+// request with a ConflictError. Single-script unicode addresses are left alone.
+func (s *ReconciliationService) applyUnicodePolicy(req models.IdentifyRequest) (models.IdentifyRequest, error) {
+	policy := s.cfg.EmailUnicodePolicy
+	if req.Email == nil || policy == "" {
+		return req, nil
+	}
+
+	email := norm.NFKC.String(*req.Email)
+	req.Email = &email
+
+	skeleton, confusable := latinSkeleton(email)
+	if !confusable {
+		return req, nil
+	}
+
+	switch policy {
+	case config.EmailUnicodeCollapse:
+		req.Email = &skeleton
+	case config.EmailUnicodeFlag:
+		return req, &ConflictError{
+			Code:       ConflictConfusableEmail,
+			Message:    fmt.Sprintf("email mixes Latin letters with lookalike characters (reads as %q)", skeleton),
+			PrimaryIDs: []int64{},
+		}
+	}
+	return req, nil
+}
+
+// latinSkeleton replaces confusable characters with their Latin lookalikes and
+// reports whether the email mixed them with Latin letters
+func latinSkeleton(email string) (string, bool) {
+	hasLatin, hasConfusable := false, false
+	skeleton := strings.Map(func(r rune) rune {
+		if latin, ok := confusables[unicode.ToLower(r)]; ok {
+			hasConfusable = true
+			return latin
+		}
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			hasLatin = true
+		}
+		return r
+	}, email)
+	return skeleton, hasLatin && hasConfusable
+}
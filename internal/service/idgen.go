@@ -0,0 +1,39 @@
+package service
+
+import "sync"
+
+// IDGenerator allocates ids for newly created contacts
+type IDGenerator interface {
+	// NextID returns the id for the next contact, or 0 to let the database assign one
+	NextID() int64
+}
+
+// DatabaseIDGenerator defers to the table's auto-increment/SERIAL column
+type DatabaseIDGenerator struct{}
+
+// NextID always lets the database pick the id
+func (DatabaseIDGenerator) NextID() int64 {
+	return 0
+}
+
+// SequentialIDGenerator hands out predictable, increasing ids, so tests can
+// assert on exact contact ids. It does not advance a Postgres SERIAL sequence.
+type SequentialIDGenerator struct {
+	mu   sync.Mutex
+	next int64
+}
+
+// NewSequentialIDGenerator creates a generator whose first id is start
+func NewSequentialIDGenerator(start int64) *SequentialIDGenerator {
+	return &SequentialIDGenerator{next: start}
+}
+
+// NextID returns the next id in the sequence
+func (g *SequentialIDGenerator) NextID() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.next
+	g.next++
+	return id
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"bitespeed/internal/metrics"
+	"bitespeed/internal/models"
+)
+
+// reportDemotion emits the demotion metric and, unless disabled, a structured log line
+func (s *ReconciliationService) reportDemotion(demotedID, primaryID int64, requestID string) {
+	metrics.PrimaryDemotions.Inc()
+	if !s.cfg.LogDemotions {
+		return
+	}
+	if requestID == "" {
+		requestID = "-"
+	}
+	log.Printf("event=primary_demoted demoted_id=%d new_primary_id=%d request_id=%s", demotedID, primaryID, requestID)
+}
+
+// recordMerge stores that a former primary was absorbed into another primary
+func (s *ReconciliationService) recordMerge(oldPrimaryID, newPrimaryID int64) error {
+	query := `INSERT INTO merged_into (old_primary_id, new_primary_id, merged_at) VALUES ($1, $2, $3)`
+	_, err := s.conn.Exec(query, oldPrimaryID, newPrimaryID, time.Now())
+	return err
+}
+
+// Lineage returns every former primary absorbed, directly or transitively, into the
+// current primary of the given contact
+func (s *ReconciliationService) Lineage(ctx context.Context, id int64) (*models.LineageResponse, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	primaryID, err := s.resolvePrimaryID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrContactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.LineageResponse{
+		ContactID:        id,
+		PrimaryContactID: primaryID,
+		Lineage:          []models.LineageEntry{},
+	}
+
+	// Walk the absorption tree breadth first, guarding against corrupted cycles
+	visited := map[int64]bool{primaryID: true}
+	frontier := []int64{primaryID}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var next []int64
+		for _, absorberID := range frontier {
+			entries, err := s.queryMergesInto(absorberID)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if visited[entry.OldPrimaryID] {
+					continue
+				}
+				visited[entry.OldPrimaryID] = true
+				entry.Depth = depth
+				response.Lineage = append(response.Lineage, entry)
+				next = append(next, entry.OldPrimaryID)
+			}
+		}
+		frontier = next
+	}
+
+	return response, nil
+}
+
+// queryMergesInto returns the merges that absorbed a primary into the given one
+func (s *ReconciliationService) queryMergesInto(newPrimaryID int64) ([]models.LineageEntry, error) {
+	query := `SELECT old_primary_id, new_primary_id, merged_at FROM merged_into 
+			  WHERE new_primary_id = $1 ORDER BY merged_at, id`
+
+	rows, err := s.conn.Query(query, newPrimaryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LineageEntry
+	for rows.Next() {
+		var entry models.LineageEntry
+		if err := rows.Scan(&entry.OldPrimaryID, &entry.NewPrimaryID, &entry.MergedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// matchCacheMaxEntries bounds each level of the cache; a full level is reset
+const matchCacheMaxEntries = 10000
+
+// matchCache remembers identifier -> primary ID lookups (positive entries) and
+// identifiers known to be absent (negative entries), each with its own TTL.
+// A zero TTL disables that level. Keys come from matchCacheKey.
+type matchCache struct {
+	mu          sync.Mutex
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+	positive    map[string]positiveEntry
+	negative    map[string]time.Time
+}
+
+// positiveEntry is a cached primary ID and its expiry
+type positiveEntry struct {
+	primaryID int64
+	expires   time.Time
+}
+
+// newMatchCache creates a cache with the given TTLs
+func newMatchCache(positiveTTL, negativeTTL time.Duration) *matchCache {
+	return &matchCache{
+		positiveTTL: positiveTTL,
+		negativeTTL: negativeTTL,
+		positive:    make(map[string]positiveEntry),
+		negative:    make(map[string]time.Time),
+	}
+}
+
+// matchCacheKey scopes an identifier by kind and account
+func matchCacheKey(kind, value string, accountID *string) string {
+	return kind + "\x00" + accountKey(accountID) + "\x00" + value
+}
+
+// lookup returns the cached primary ID (found=true) or a cached absence
+// (found=false, cached=true); cached=false means the database must be asked
+func (c *matchCache) lookup(key string) (primaryID int64, found, cached bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := c.positive[key]; ok {
+		if now.Before(entry.expires) {
+			return entry.primaryID, true, true
+		}
+		delete(c.positive, key)
+	}
+	if expires, ok := c.negative[key]; ok {
+		if now.Before(expires) {
+			return 0, false, true
+		}
+		delete(c.negative, key)
+	}
+	return 0, false, false
+}
+
+// storeFound caches the primary an identifier resolved to
+func (c *matchCache) storeFound(key string, primaryID int64) {
+	if c.positiveTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.positive) >= matchCacheMaxEntries {
+		c.positive = make(map[string]positiveEntry)
+	}
+	c.positive[key] = positiveEntry{primaryID: primaryID, expires: time.Now().Add(c.positiveTTL)}
+}
+
+// storeAbsent caches that an identifier has no contact
+func (c *matchCache) storeAbsent(key string) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.negative) >= matchCacheMaxEntries {
+		c.negative = make(map[string]time.Time)
+	}
+	c.negative[key] = time.Now().Add(c.negativeTTL)
+}
+
+// forgetAbsent drops negative entries for identifiers that now exist
+func (c *matchCache) forgetAbsent(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.negative, key)
+	}
+}
+
+// resetPositive drops every positive entry; called when a primary changes
+func (c *matchCache) resetPositive() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.positive = make(map[string]positiveEntry)
+}
+
+// staleMatches collects the cache entries a transaction's writes make stale.
+// They are dropped once the transaction commits: dropped earlier, a concurrent
+// lookup could cache the committed state again before it changes, and a
+// rolled-back transaction leaves nothing stale.
+type staleMatches struct {
+	absent   []string
+	positive bool
+}
+
+// forgetAbsent marks negative entries for identifiers the transaction stored
+func (m *staleMatches) forgetAbsent(keys ...string) {
+	m.absent = append(m.absent, keys...)
+}
+
+// resetPositive marks every positive entry stale; a primary changed
+func (m *staleMatches) resetPositive() {
+	m.positive = true
+}
+
+// apply drops the collected entries from the cache
+func (m *staleMatches) apply(c *matchCache) {
+	c.forgetAbsent(m.absent...)
+	if m.positive {
+		c.resetPositive()
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+)
+
+func TestMatchCacheInvalidatedOnCommit(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit bool
+	}{
+		{name: "committed", commit: true},
+		{name: "rolled back"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, func(cfg *config.Config) {
+				cfg.MatchCacheTTL = time.Minute
+				cfg.MatchCacheNegativeTTL = time.Minute
+			})
+			insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("111"), nil, "primary", time.Now())
+
+			ctx := context.Background()
+			// Cache a primary and an absence
+			if _, found, err := s.FindPrimaryID(ctx, ptr("doc@hillvalley.edu"), nil, nil); err != nil || !found {
+				t.Fatalf("lookup = %v, %v, want found", found, err)
+			}
+			if _, found, err := s.FindPrimaryID(ctx, ptr("marty@hillvalley.edu"), nil, nil); err != nil || found {
+				t.Fatalf("lookup = %v, %v, want absent", found, err)
+			}
+			present := s.identifierCacheKeys(models.IdentifyRequest{Email: ptr("doc@hillvalley.edu")})[0]
+			absent := s.identifierCacheKeys(models.IdentifyRequest{Email: ptr("marty@hillvalley.edu")})[0]
+			cached := func() (bool, bool) {
+				_, _, presentCached := s.matches.lookup(present)
+				_, _, absentCached := s.matches.lookup(absent)
+				return presentCached, absentCached
+			}
+
+			tx, err := s.db.Conn.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			bound := s.withConn(tx)
+			if _, err := bound.insertContact(models.IdentifyRequest{Email: ptr("marty@hillvalley.edu")}, nil, "primary"); err != nil {
+				t.Fatalf("failed to insert: %v", err)
+			}
+			if err := bound.updateContactPrecedence(1, "primary", nil); err != nil {
+				t.Fatalf("failed to update: %v", err)
+			}
+
+			if presentCached, absentCached := cached(); !presentCached || !absentCached {
+				t.Fatalf("cache invalidated before the transaction ended: primary cached %v, absence cached %v", presentCached, absentCached)
+			}
+
+			if tt.commit {
+				err = bound.commit(tx)
+			} else {
+				err = tx.Rollback()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			presentCached, absentCached := cached()
+			if presentCached == tt.commit || absentCached == tt.commit {
+				t.Errorf("primary cached %v, absence cached %v, want both %v", presentCached, absentCached, !tt.commit)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"slices"
+	"strings"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/models"
+)
+
+// ErrSwappedFields is returned under SWAPPED_FIELDS_POLICY=reject when the email
+// looks like a phone number or the phone number looks like an email
+var ErrSwappedFields = errors.New("email and phoneNumber appear to be swapped")
+
+// ErrInvalidEmail is returned when the email is not a plain addr-spec such as
+// "user@example.com"
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// validEmail reports whether email is a bare RFC 5322 address: no display
+// name, angle brackets, whitespace or missing local part/domain
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Name == "" && addr.Address == email
+}
+
+// normalizeRequest returns the identifiers in the form used for matching and storage.
+// Blank values are treated as absent.
+func normalizeRequest(req models.IdentifyRequest) models.IdentifyRequest {
+	return models.IdentifyRequest{
+		Email:       normalizeField(req.Email),
+		PhoneNumber: normalizeField(req.PhoneNumber),
+		AccountID:   normalizeField(req.AccountID),
+
+		EmailVerified:       req.EmailVerified,
+		PhoneNumberVerified: req.PhoneNumberVerified,
+	}
+}
+
+// normalizeField trims surrounding whitespace, returning nil for blank values
+func normalizeField(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// emailMatchKey returns the form of an email compared during matching. For domains
+// listed in EmailCanonicalProviders the local part loses its +tag and dots
+// (alice.b+shop@gmail.com matches alicebob@gmail.com); other emails are returned
+// unchanged with ok=false. Contacts store the key in email_match_key, so
+// matching is an indexed equality.
+func (s *ReconciliationService) emailMatchKey(email string) (key, domain string, ok bool) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email, "", false
+	}
+	local, domain := strings.ToLower(email[:at]), strings.ToLower(email[at+1:])
+	if !slices.Contains(s.cfg.EmailCanonicalProviders, domain) {
+		return email, "", false
+	}
+
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	local = strings.ReplaceAll(local, ".", "")
+	return local + "@" + domain, domain, true
+}
+
+// storedEmailMatchKey returns the value of the email_match_key column for an
+// email: its match key on EmailCanonicalProviders domains, NULL otherwise
+func (s *ReconciliationService) storedEmailMatchKey(email *string) *string {
+	if email == nil {
+		return nil
+	}
+	if key, _, ok := s.emailMatchKey(*email); ok {
+		return &key
+	}
+	return nil
+}
+
+// matchKeyBackfillBatch is the number of contacts BackfillEmailMatchKeys reads at once
+const matchKeyBackfillBatch = 500
+
+// BackfillEmailMatchKeys stores the match key of emails on EmailCanonicalProviders
+// domains that have none yet, such as rows written before their provider was
+// configured. It scans each provider's contacts once per start and is bound to
+// ctx only, as DB_TIMEOUT_MS is sized for single requests.
+func (s *ReconciliationService) BackfillEmailMatchKeys(ctx context.Context) error {
+	conn := reboundConn{conn: s.db.Conn, db: s.db, ctx: ctx}
+	updated := 0
+	for _, domain := range s.cfg.EmailCanonicalProviders {
+		var lastID int64
+		for {
+			// Rows are read in batches and closed before updating, as SQLite has a single connection
+			query := `SELECT id, email FROM contacts 
+					  WHERE email_match_key IS NULL AND LOWER(email) LIKE $1 AND id > $2 ORDER BY id LIMIT $3`
+			rows, err := conn.Query(query, "%@"+domain, lastID, matchKeyBackfillBatch)
+			if err != nil {
+				return fmt.Errorf("failed to read emails of %s: %w", domain, err)
+			}
+			type pending struct {
+				id    int64
+				email string
+			}
+			var batch []pending
+			for rows.Next() {
+				var p pending
+				if err := rows.Scan(&p.id, &p.email); err != nil {
+					rows.Close()
+					return fmt.Errorf("failed to scan email of %s: %w", domain, err)
+				}
+				batch = append(batch, p)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("failed to read emails of %s: %w", domain, err)
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			for _, p := range batch {
+				lastID = p.id
+				key := s.storedEmailMatchKey(&p.email)
+				if key == nil {
+					continue
+				}
+				if _, err := conn.Exec(`UPDATE contacts SET email_match_key = $1 WHERE id = $2`, *key, p.id); err != nil {
+					return fmt.Errorf("failed to store match key of contact %d: %w", p.id, err)
+				}
+				updated++
+			}
+		}
+	}
+
+	if updated > 0 {
+		log.Printf("Backfilled the email match key of %d contacts", updated)
+	}
+	return nil
+}
+
+// BackfillNormalizedIdentifiers rewrites stored emails and phone numbers that
+// EMAIL_NORMALIZATION and PHONE_NORMALIZATION now store differently, such as
+// mixed-case emails or numbers stored verbatim before strip-format became the
+// default, so they match requests again. Clusters that end up sharing an
+// identifier merge on the next identify that sends it. Like
+// BackfillEmailMatchKeys it runs once per start, bound to ctx only.
+func (s *ReconciliationService) BackfillNormalizedIdentifiers(ctx context.Context) error {
+	conn := reboundConn{conn: s.db.Conn, db: s.db, ctx: ctx}
+	emails, err := renormalizeColumn(conn, "email", s.emailNormalizer, func(id int64, email string) error {
+		// The match key is derived from the email, so it is rewritten with it
+		_, err := conn.Exec(`UPDATE contacts SET email = $1, email_match_key = $2 WHERE id = $3`, email, s.storedEmailMatchKey(&email), id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	phones, err := renormalizeColumn(conn, "phone_number", s.phoneNormalizer, func(id int64, phone string) error {
+		_, err := conn.Exec(`UPDATE contacts SET phone_number = $1 WHERE id = $2`, phone, id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if emails > 0 || phones > 0 {
+		log.Printf("Renormalized %d stored emails and %d stored phone numbers", emails, phones)
+	}
+	return nil
+}
+
+// renormalizeColumn calls store for every contact whose value in column the
+// normalizer changes and returns how many it stored. Values it would blank out
+// are left alone, as no contact may lose its last identifier.
+func renormalizeColumn(conn querier, column string, normalizer *Normalizer, store func(id int64, value string) error) (int, error) {
+	updated := 0
+	var lastID int64
+	for {
+		// Rows are read in batches and closed before updating, as SQLite has a single connection
+		query := `SELECT id, ` + column + ` FROM contacts WHERE ` + column + ` IS NOT NULL AND id > $1 ORDER BY id LIMIT $2`
+		rows, err := conn.Query(query, lastID, matchKeyBackfillBatch)
+		if err != nil {
+			return updated, fmt.Errorf("failed to read %s: %w", column, err)
+		}
+		type pending struct {
+			id    int64
+			value string
+		}
+		var batch []pending
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.id, &p.value); err != nil {
+				rows.Close()
+				return updated, fmt.Errorf("failed to scan %s: %w", column, err)
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, fmt.Errorf("failed to read %s: %w", column, err)
+		}
+		if len(batch) == 0 {
+			return updated, nil
+		}
+
+		for _, p := range batch {
+			lastID = p.id
+			normalized := normalizer.Normalize(&p.value)
+			if normalized == nil || *normalized == p.value {
+				continue
+			}
+			if err := store(p.id, *normalized); err != nil {
+				return updated, fmt.Errorf("failed to store %s of contact %d: %w", column, p.id, err)
+			}
+			updated++
+		}
+	}
+}
+
+// fixSwappedFields detects an email that looks like a phone number or a phone
+// number that looks like an email. Depending on SWAPPED_FIELDS_POLICY it swaps
+// them back ("swap") or rejects the request ("reject"); a swap only happens
+// when neither field would end up holding the wrong kind of value.
+func (s *ReconciliationService) fixSwappedFields(req models.IdentifyRequest) (models.IdentifyRequest, error) {
+	policy := s.cfg.SwappedFieldsPolicy
+	if policy == "" {
+		return req, nil
+	}
+
+	emailIsPhone := req.Email != nil && looksLikePhone(*req.Email)
+	phoneIsEmail := req.PhoneNumber != nil && strings.Contains(*req.PhoneNumber, "@")
+	if !emailIsPhone && !phoneIsEmail {
+		return req, nil
+	}
+
+	switch policy {
+	case config.SwappedFieldsReject:
+		return req, fmt.Errorf("%w: send the address containing @ as email and the digits as phoneNumber", ErrSwappedFields)
+	case config.SwappedFieldsSwap:
+		if (req.Email == nil || emailIsPhone) && (req.PhoneNumber == nil || phoneIsEmail) {
+			req.Email, req.PhoneNumber = req.PhoneNumber, req.Email
+		}
+	}
+	return req, nil
+}
+
+// looksLikePhone reports whether a value is made of digits and common phone
+// punctuation only
+func looksLikePhone(value string) bool {
+	digits := 0
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case strings.ContainsRune("+-() .", r):
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
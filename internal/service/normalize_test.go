@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bitespeed/internal/config"
+)
+
+// withGmail treats gmail.com as a provider-canonical domain
+func withGmail(cfg *config.Config) {
+	cfg.EmailCanonicalProviders = []string{"gmail.com"}
+}
+
+func TestEmailMatchKey(t *testing.T) {
+	s := newTestService(t, withGmail)
+	tests := []struct {
+		email     string
+		key       string
+		canonical bool
+	}{
+		{"alice@gmail.com", "alice@gmail.com", true},
+		{"alice+shop@gmail.com", "alice@gmail.com", true},
+		{"a.l.ice@gmail.com", "alice@gmail.com", true},
+		{"A.Lice+Shop@Gmail.com", "alice@gmail.com", true},
+		{"a.lice+shop@example.com", "a.lice+shop@example.com", false},
+	}
+	for _, tt := range tests {
+		key, _, canonical := s.emailMatchKey(tt.email)
+		if key != tt.key || canonical != tt.canonical {
+			t.Errorf("emailMatchKey(%q) = %q, %v, want %q, %v", tt.email, key, canonical, tt.key, tt.canonical)
+		}
+	}
+}
+
+func TestIdentifyMatchesProviderCanonicalEmails(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  string
+		request string
+		// whether the second request joins the first contact's cluster
+		joins bool
+	}{
+		{name: "plus-addressed gmail", stored: "alice@gmail.com", request: "alice+shop@gmail.com", joins: true},
+		{name: "dotted gmail", stored: "alice@gmail.com", request: "a.lice@gmail.com", joins: true},
+		{name: "plus-addressed other domain", stored: "alice@example.com", request: "alice+shop@example.com", joins: false},
+		{name: "dotted other domain", stored: "alice@example.com", request: "a.lice@example.com", joins: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, withGmail)
+			first := identify(t, s, ptr(tt.stored), ptr("111"))
+			second := identify(t, s, ptr(tt.request), ptr("222"))
+
+			if joined := second.Contact.PrimaryContactID == first.Contact.PrimaryContactID; joined != tt.joins {
+				t.Errorf("%s joined the cluster of %s: %v, want %v", tt.request, tt.stored, joined, tt.joins)
+			}
+			// Matching never rewrites the stored email
+			if tt.joins && second.Contact.Emails[0] != tt.stored {
+				t.Errorf("emails = %v, want %s first", second.Contact.Emails, tt.stored)
+			}
+		})
+	}
+}
+
+func TestBackfillEmailMatchKeys(t *testing.T) {
+	s := newTestService(t, withGmail)
+	// Written before gmail.com was a provider, so without a match key
+	insertRow(t, s, 1, ptr("a.lice@gmail.com"), ptr("111"), nil, "primary", time.Now())
+
+	if err := s.BackfillEmailMatchKeys(context.Background()); err != nil {
+		t.Fatalf("BackfillEmailMatchKeys failed: %v", err)
+	}
+	var key string
+	if err := s.conn.QueryRow(`SELECT email_match_key FROM contacts WHERE id = 1`).Scan(&key); err != nil {
+		t.Fatalf("failed to read match key: %v", err)
+	}
+	if key != "alice@gmail.com" {
+		t.Errorf("email_match_key = %q, want alice@gmail.com", key)
+	}
+
+	response := identify(t, s, ptr("alice+shop@gmail.com"), nil)
+	if response.Contact.PrimaryContactID != 1 {
+		t.Errorf("primaryContactId = %d, want the backfilled contact 1", response.Contact.PrimaryContactID)
+	}
+}
+
+func TestBackfillNormalizedPhoneNumbers(t *testing.T) {
+	tests := []struct {
+		stored string
+		want   string
+	}{
+		{stored: "+1 (555) 123-4567", want: "15551234567"},
+		{stored: " 44-20-7946-0018", want: "442079460018"},
+		{stored: "15550000000", want: "15550000000"},
+		// Would be blanked out, so it is kept
+		{stored: "( )", want: "( )"},
+	}
+
+	s := newTestService(t, nil)
+	for i, tt := range tests {
+		insertRow(t, s, int64(i+1), nil, ptr(tt.stored), nil, "primary", time.Now())
+	}
+
+	if err := s.BackfillNormalizedIdentifiers(context.Background()); err != nil {
+		t.Fatalf("BackfillNormalizedIdentifiers failed: %v", err)
+	}
+	for i, tt := range tests {
+		var phone string
+		if err := s.conn.QueryRow(`SELECT phone_number FROM contacts WHERE id = $1`, i+1).Scan(&phone); err != nil {
+			t.Fatalf("failed to read phone number: %v", err)
+		}
+		if phone != tt.want {
+			t.Errorf("%q stored as %q, want %q", tt.stored, phone, tt.want)
+		}
+	}
+
+	response := identify(t, s, nil, ptr("+1 555 123 4567"))
+	if response.Contact.PrimaryContactID != 1 {
+		t.Errorf("primaryContactId = %d, want the renormalized contact 1", response.Contact.PrimaryContactID)
+	}
+}
+
+func TestBackfillNormalizedEmails(t *testing.T) {
+	tests := []struct {
+		stored string
+		want   string
+		// match key stored afterwards, "" for NULL
+		key string
+	}{
+		{stored: "Doc@HillValley.edu", want: "doc@hillvalley.edu"},
+		{stored: " marty@hillvalley.edu ", want: "marty@hillvalley.edu"},
+		{stored: "A.Lice+Shop@Gmail.com", want: "a.lice+shop@gmail.com", key: "alice@gmail.com"},
+		{stored: "biff@hillvalley.edu", want: "biff@hillvalley.edu"},
+	}
+
+	s := newTestService(t, withGmail)
+	for i, tt := range tests {
+		insertRow(t, s, int64(i+1), ptr(tt.stored), nil, nil, "primary", time.Now())
+	}
+
+	if err := s.BackfillNormalizedIdentifiers(context.Background()); err != nil {
+		t.Fatalf("BackfillNormalizedIdentifiers failed: %v", err)
+	}
+	for i, tt := range tests {
+		var email, key string
+		if err := s.conn.QueryRow(`SELECT email, COALESCE(email_match_key, '') FROM contacts WHERE id = $1`, i+1).Scan(&email, &key); err != nil {
+			t.Fatalf("failed to read email: %v", err)
+		}
+		if email != tt.want || key != tt.key {
+			t.Errorf("%q stored as %q with match key %q, want %q with %q", tt.stored, email, key, tt.want, tt.key)
+		}
+	}
+
+	response := identify(t, s, ptr("DOC@hillvalley.edu"), nil)
+	if response.Contact.PrimaryContactID != 1 {
+		t.Errorf("primaryContactId = %d, want the renormalized contact 1", response.Contact.PrimaryContactID)
+	}
+}
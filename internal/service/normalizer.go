@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizationSteps are the named steps a Normalizer can be composed of
+var normalizationSteps = map[string]func(string) string{
+	"trim":         strings.TrimSpace,
+	"lowercase":    strings.ToLower,
+	"nfkc":         norm.NFKC.String,
+	"strip-format": NormalizePhoneNumber,
+	"strip-plus": func(value string) string {
+		local, domain, ok := strings.Cut(value, "@")
+		if !ok {
+			return value
+		}
+		local, _, _ = strings.Cut(local, "+")
+		return local + "@" + domain
+	},
+	"e164": func(value string) string {
+		value = strings.TrimSpace(value)
+		if rest, ok := strings.CutPrefix(value, "00"); ok {
+			value = "+" + rest
+		}
+		var b strings.Builder
+		for i, r := range value {
+			if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	},
+}
+
+// phoneFormatting are the separators people type inside phone numbers
+var phoneFormatting = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "")
+
+// NormalizePhoneNumber removes spaces, dashes, parentheses and a leading "+" so
+// "+1 (555) 123-4567" and "15551234567" compare equal. Digits are never added,
+// dropped or reordered, so numbers with different country codes stay distinct.
+func NormalizePhoneNumber(phone string) string {
+	return strings.TrimPrefix(phoneFormatting.Replace(strings.TrimSpace(phone)), "+")
+}
+
+// Normalizer applies an ordered list of normalization steps to one identifier
+// field. The same Normalizer runs before lookups and inserts, so stored values
+// and request values are always compared in the same form.
+type Normalizer struct {
+	steps []func(string) string
+}
+
+// NewNormalizer builds a Normalizer from step names such as "trim", "lowercase",
+// "strip-plus", "strip-format", "e164" and "nfkc", applied in the given order.
+// An unknown name is an error: skipping it would silently match differently
+// than configured.
+func NewNormalizer(names []string) (*Normalizer, error) {
+	n := &Normalizer{}
+	for _, name := range names {
+		step, ok := normalizationSteps[name]
+		if !ok {
+			known := slices.Sorted(maps.Keys(normalizationSteps))
+			return nil, fmt.Errorf("unknown normalization step %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		n.steps = append(n.steps, step)
+	}
+	return n, nil
+}
+
+// Normalize runs the steps over a value; a value that ends up blank becomes nil
+func (n *Normalizer) Normalize(value *string) *string {
+	if value == nil {
+		return nil
+	}
+
+	normalized := *value
+	for _, step := range n.steps {
+		normalized = step(normalized)
+	}
+	if strings.TrimSpace(normalized) == "" {
+		return nil
+	}
+	return &normalized
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/database"
+)
+
+func TestNewNormalizer(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string
+		input string
+		want  string
+		err   string
+	}{
+		{name: "no steps", input: " Doc@HillValley.edu ", want: " Doc@HillValley.edu "},
+		{name: "default email", steps: []string{"trim", "lowercase"}, input: " Doc+lab@HillValley.edu ", want: "doc+lab@hillvalley.edu"},
+		{name: "strip plus", steps: []string{"trim", "lowercase", "strip-plus"}, input: " Doc+lab@HillValley.edu ", want: "doc@hillvalley.edu"},
+		{name: "default phone", steps: []string{"trim", "strip-format"}, input: "+1 (555) 123-4567", want: "15551234567"},
+		{name: "e164", steps: []string{"e164"}, input: "0044 20 7946 0018", want: "+442079460018"},
+		{name: "unknown step", steps: []string{"trim", "lowercsae"}, err: `unknown normalization step "lowercsae"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewNormalizer(tt.steps)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewNormalizer failed: %v", err)
+			}
+			if got := n.Normalize(&tt.input); got == nil || *got != tt.want {
+				t.Errorf("Normalize(%q) = %v, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReconciliationServiceRejectsUnknownSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		err       string
+	}{
+		{name: "email", configure: func(cfg *config.Config) { cfg.EmailNormalization = []string{"trim", "nfc"} }, err: "invalid EMAIL_NORMALIZATION"},
+		{name: "phone", configure: func(cfg *config.Config) { cfg.PhoneNormalization = []string{"E164"} }, err: "invalid PHONE_NORMALIZATION"},
+	}
+
+	db, err := database.New("file:normalizer-test?mode=memory&cache=shared", database.Options{})
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			tt.configure(cfg)
+			if _, err := NewReconciliationService(db, cfg); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
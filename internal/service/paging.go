@@ -0,0 +1,43 @@
+package service
+
+import (
+	"slices"
+
+	"bitespeed/internal/models"
+)
+
+// pageContact trims a cluster's arrays to one page, recording their full
+// lengths in Total. The primary's email and phone number stay first on every
+// page and are not counted against offset or limit.
+func pageContact(contact *models.ContactResponse, primaryEmail, primaryPhone string, offset, limit int) {
+	contact.Total = &models.ContactTotals{
+		Emails:              len(contact.Emails),
+		PhoneNumbers:        len(contact.PhoneNumbers),
+		SecondaryContactIDs: len(contact.SecondaryContactIDs),
+	}
+	contact.Emails = pageAfterPinned(contact.Emails, primaryEmail, offset, limit)
+	contact.PhoneNumbers = pageAfterPinned(contact.PhoneNumbers, primaryPhone, offset, limit)
+	contact.SecondaryContactIDs = page(contact.SecondaryContactIDs, offset, limit)
+}
+
+// pageAfterPinned pages values without pinned, which leads the result when set
+func pageAfterPinned(values []string, pinned string, offset, limit int) []string {
+	if pinned == "" || !slices.Contains(values, pinned) {
+		return page(values, offset, limit)
+	}
+	rest := slices.DeleteFunc(slices.Clone(values), func(value string) bool { return value == pinned })
+	return append([]string{pinned}, page(rest, offset, limit)...)
+}
+
+// page returns values[offset:offset+limit], clamped to the slice; limit 0
+// means no limit
+func page[T any](values []T, offset, limit int) []T {
+	if offset >= len(values) {
+		return []T{}
+	}
+	values = values[offset:]
+	if limit > 0 && limit < len(values) {
+		values = values[:limit]
+	}
+	return values
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuildResponsePaging(t *testing.T) {
+	s := newTestService(t, nil)
+	now := time.Now()
+	insertRow(t, s, 1, ptr("a@example.com"), ptr("111"), nil, "primary", now)
+	insertRow(t, s, 2, ptr("b@example.com"), ptr("222"), ptr(int64(1)), "secondary", now.Add(time.Minute))
+	insertRow(t, s, 3, ptr("c@example.com"), ptr("333"), ptr(int64(1)), "secondary", now.Add(2*time.Minute))
+	insertRow(t, s, 4, ptr("d@example.com"), nil, ptr(int64(1)), "secondary", now.Add(3*time.Minute))
+	// A deleted duplicate of an active email, and one only found on deleted contacts
+	insertRow(t, s, 5, ptr("d@example.com"), nil, ptr(int64(1)), "secondary", now.Add(4*time.Minute))
+	insertRow(t, s, 6, ptr("gone@example.com"), nil, ptr(int64(1)), "secondary", now.Add(5*time.Minute))
+	if _, err := s.conn.Exec(`UPDATE contacts SET deleted_at = $1 WHERE id IN (5, 6)`, now); err != nil {
+		t.Fatalf("failed to delete contacts: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		opts             IdentifyOptions
+		emails           []string
+		phones           []string
+		secondaryIDs     []int64
+		paged            bool
+		historicalEmails []string
+	}{
+		{
+			name:         "no paging by default",
+			emails:       []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"},
+			phones:       []string{"111", "222", "333"},
+			secondaryIDs: []int64{2, 3, 4},
+		},
+		{
+			name:         "limit keeps the primary's values first",
+			opts:         IdentifyOptions{Limit: 1},
+			emails:       []string{"a@example.com", "b@example.com"},
+			phones:       []string{"111", "222"},
+			secondaryIDs: []int64{2},
+			paged:        true,
+		},
+		{
+			name:         "offset skips values after the primary's",
+			opts:         IdentifyOptions{Limit: 1, Offset: 2},
+			emails:       []string{"a@example.com", "d@example.com"},
+			phones:       []string{"111"},
+			secondaryIDs: []int64{4},
+			paged:        true,
+		},
+		{
+			name:             "historical identifiers ignore the page",
+			opts:             IdentifyOptions{Limit: 1, IncludeHistorical: true},
+			emails:           []string{"a@example.com", "b@example.com"},
+			phones:           []string{"111", "222"},
+			secondaryIDs:     []int64{2},
+			paged:            true,
+			historicalEmails: []string{"gone@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := s.buildResponse(1, tt.opts)
+			if err != nil {
+				t.Fatalf("buildResponse failed: %v", err)
+			}
+			assertContact(t, response.Contact, 1, tt.emails, tt.phones, tt.secondaryIDs)
+
+			total := response.Contact.Total
+			if !tt.paged && total != nil {
+				t.Errorf("unpaged response has total %+v", *total)
+			}
+			if tt.paged && (total == nil || total.Emails != 4 || total.PhoneNumbers != 3 || total.SecondaryContactIDs != 3) {
+				t.Errorf("total = %+v, want 4 emails, 3 phone numbers and 3 secondaries", total)
+			}
+			if tt.opts.IncludeHistorical && !slices.Equal(response.Contact.HistoricalEmails, tt.historicalEmails) {
+				t.Errorf("historicalEmails = %v, want %v", response.Contact.HistoricalEmails, tt.historicalEmails)
+			}
+		})
+	}
+}
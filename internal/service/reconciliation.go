@@ -1,131 +1,714 @@
 package service
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 
+	"bitespeed/internal/config"
 	"bitespeed/internal/database"
+	"bitespeed/internal/decisionlog"
 	"bitespeed/internal/models"
 )
 
+// querier is what the service runs its statements on
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqlConn is the part of *sql.DB and *sql.Tx a reboundConn runs on
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// reboundConn runs statements on conn under ctx after rebinding their $N
+// placeholders for the database driver; every service query goes through one
+type reboundConn struct {
+	conn sqlConn
+	db   *database.DB
+	ctx  context.Context
+}
+
+func (c reboundConn) Exec(query string, args ...any) (sql.Result, error) {
+	return c.conn.ExecContext(c.ctx, c.db.Rebind(query), args...)
+}
+
+func (c reboundConn) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.conn.QueryContext(c.ctx, c.db.Rebind(query), args...)
+}
+
+func (c reboundConn) QueryRow(query string, args ...any) *sql.Row {
+	return c.conn.QueryRowContext(c.ctx, c.db.Rebind(query), args...)
+}
+
 // ReconciliationService handles identity reconciliation logic
 type ReconciliationService struct {
 	db *database.DB
+	// conn runs on db.Conn, or the transaction of a service bound by withConn,
+	// under ctx (the request context of a service bound by withContext)
+	conn      querier
+	ctx       context.Context
+	cfg       *config.Config
+	idGen     IDGenerator
+	decisions *decisionlog.Logger
+	audit     chan auditRecord
+	auditDone chan struct{}
+	matches   *matchCache
+	// stale collects what the transaction of a service bound by withConn
+	// makes stale in matches, applied by commit
+	stale    *staleMatches
+	velocity *velocityTracker
+	// dryRun is set on the copy of the service projecting a dry run, which
+	// must leave in-memory state such as the velocity counters untouched
+	dryRun bool
+
+	resolver         ConflictResolver
+	resolverTimeout  time.Duration
+	resolverFallback ConflictDecision
+
+	emailNormalizer *Normalizer
+	phoneNormalizer *Normalizer
 }
 
-// NewReconciliationService creates a new reconciliation service
-func NewReconciliationService(db *database.DB) *ReconciliationService {
-	return &ReconciliationService{db: db}
+// NewReconciliationService creates a new reconciliation service. It fails on
+// normalization steps it does not know.
+func NewReconciliationService(db *database.DB, cfg *config.Config) (*ReconciliationService, error) {
+	emailNormalizer, err := NewNormalizer(cfg.EmailNormalization)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_NORMALIZATION: %w", err)
+	}
+	phoneNormalizer, err := NewNormalizer(cfg.PhoneNormalization)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PHONE_NORMALIZATION: %w", err)
+	}
+
+	return &ReconciliationService{
+		db:       db,
+		conn:     reboundConn{conn: db.Conn, db: db, ctx: context.Background()},
+		ctx:      context.Background(),
+		cfg:      cfg,
+		idGen:    DatabaseIDGenerator{},
+		matches:  newMatchCache(cfg.MatchCacheTTL, cfg.MatchCacheNegativeTTL),
+		velocity: newVelocityTracker(cfg.JoinVelocityLimit, cfg.JoinVelocityWindow),
+
+		emailNormalizer: emailNormalizer,
+		phoneNormalizer: phoneNormalizer,
+	}, nil
 }
 
-// Identify handles the identity reconciliation logic
-func (s *ReconciliationService) Identify(req models.IdentifyRequest) (*models.IdentifyResponse, error) {
-	// Find existing contacts matching email OR phone number
-	linkedContacts, err := s.findLinkedContacts(req.Email, req.PhoneNumber)
+// withConn returns a copy of the service running its statements on conn
+func (s *ReconciliationService) withConn(conn sqlConn) *ReconciliationService {
+	bound := *s
+	bound.conn = reboundConn{conn: conn, db: s.db, ctx: s.ctx}
+	bound.stale = &staleMatches{}
+	return &bound
+}
+
+// commit commits tx, the transaction s is bound to, and only then drops the
+// match cache entries its writes made stale
+func (s *ReconciliationService) commit(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.stale.apply(s.matches)
+	return nil
+}
+
+// withContext returns a copy of the service running its statements under ctx,
+// bounded by the configured DB timeout, so a cancelled request or an expired
+// deadline aborts its queries; the caller must call cancel when done
+func (s *ReconciliationService) withContext(ctx context.Context) (*ReconciliationService, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if s.cfg.DBTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.DBTimeout)
+	}
+	bound := *s
+	bound.ctx = ctx
+	bound.conn = reboundConn{conn: s.db.Conn, db: s.db, ctx: ctx}
+	return &bound, cancel
+}
+
+// SetDecisionLogger enables emitting a decision event for every identify
+func (s *ReconciliationService) SetDecisionLogger(logger *decisionlog.Logger) {
+	s.decisions = logger
+}
+
+// logDecision emits the hashed decision event when decision logging is enabled
+func (s *ReconciliationService) logDecision(req models.IdentifyRequest, response *models.IdentifyResponse, result reconcileResult) {
+	if s.decisions == nil {
+		return
+	}
+
+	s.decisions.Log(decisionlog.Event{
+		EmailHash:   s.decisions.Hash(req.Email),
+		PhoneHash:   s.decisions.Hash(req.PhoneNumber),
+		AccountHash: s.decisions.Hash(req.AccountID),
+		ClusterSize: len(response.Contact.SecondaryContactIDs) + 1,
+		Action:      result.action,
+		Confidence:  result.confidence,
+	})
+}
+
+// SetIDGenerator replaces the id allocation used when creating contacts
+func (s *ReconciliationService) SetIDGenerator(gen IDGenerator) {
+	s.idGen = gen
+}
+
+// IdentifyOptions controls optional parts of the identify response
+type IdentifyOptions struct {
+	// IncludeHistorical also reports identifiers from soft-deleted cluster members
+	IncludeHistorical bool
+	// EchoNormalizedInput adds the normalized email/phone used for matching to the response
+	EchoNormalizedInput bool
+	// Verbose reports the reconciliation outcome as the response's resolution
+	Verbose bool
+	// Timestamps adds the primary contact's createdAt/updatedAt to the response
+	Timestamps bool
+	// DryRun projects the reconciliation from the current contacts without
+	// writing anything and reports the writes it would make alongside the
+	// projected response
+	DryRun bool
+	// Limit and Offset page the emails, phoneNumbers and secondaryContactIds
+	// arrays (Limit 0 means no limit); see pageContact
+	Limit  int
+	Offset int
+	// PrimaryStrategy overrides the configured primary selection for this request
+	PrimaryStrategy string
+	// RequestID identifies the triggering request in logs
+	RequestID string
+
+	// restoring is set by Restore, whose request values always exist as a
+	// primary already, so the exact-match fast path must not answer it
+	restoring bool
+	// resolution is the conflict resolver's decision, taken before the transaction
+	resolution *clusterResolution
+}
+
+// ValidPrimaryStrategy reports whether name is a known primary-selection strategy
+func ValidPrimaryStrategy(name string) bool {
+	switch name {
+	case config.PrimaryStrategyOldest, config.PrimaryStrategyLowestID, config.PrimaryStrategyVerified:
+		return true
+	}
+	return false
+}
+
+// Identify handles the identity reconciliation logic. The whole reconciliation
+// runs in one transaction, so a failure never leaves a half-linked graph behind.
+// Concurrent calls for the same new identifiers would each create a primary;
+// the database aborts all but one transaction, or VerifySinglePrimary finds
+// the extra primary, and the others start over, reconciling against the winner.
+// A dry run only reads; see projectIdentify.
+func (s *ReconciliationService) Identify(ctx context.Context, req models.IdentifyRequest, opts IdentifyOptions) (*models.IdentifyResponse, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	req, err := s.normalize(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find linked contacts: %w", err)
+		return nil, err
 	}
 
-	var primaryContact *models.Contact
+	if opts.PrimaryStrategy == "" {
+		opts.PrimaryStrategy = s.cfg.PrimaryStrategy
+	}
+
+	if opts.DryRun {
+		response, result, err := s.projectIdentify(req, opts)
+		if err != nil {
+			return nil, err
+		}
+		response.Outcome = result.action
+		if opts.Verbose {
+			response.Resolution = result.action
+		}
+		return response, nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		// The resolver may take a while to answer, so it is asked before the
+		// transaction begins and the transaction checks the clusters are unchanged
+		if s.resolver != nil {
+			if opts.resolution, err = s.resolveConflictAhead(req, opts, nil); err != nil {
+				return nil, fmt.Errorf("failed to resolve cluster conflict: %w", err)
+			}
+		}
+		response, result, err := s.identifyTx(req, opts)
+		if retryable(err) && attempt < s.cfg.IdentifyMaxRetries {
+			log.Printf("Identify aborted by a concurrent request, retrying (attempt %d/%d): %v", attempt+1, s.cfg.IdentifyMaxRetries, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		response.Outcome = result.action
+		if opts.Verbose {
+			response.Resolution = result.action
+		}
+		s.logDecision(req, response, result)
+		return response, nil
+	}
+}
+
+// retryable reports whether a transaction was aborted by a concurrent writer
+// and may succeed when started over
+func retryable(err error) bool {
+	return database.IsRetryable(err) || errors.Is(err, errMultiplePrimaries) || errors.Is(err, errClustersChanged)
+}
+
+// identifyTx runs one identify attempt in its own transaction
+func (s *ReconciliationService) identifyTx(req models.IdentifyRequest, opts IdentifyOptions) (*models.IdentifyResponse, reconcileResult, error) {
+	tx, err := s.db.Conn.BeginTx(s.ctx, s.db.SerializableTx())
+	if err != nil {
+		return nil, reconcileResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	s = s.withConn(tx)
+
+	response, result, err := s.identify(req, opts)
+	var conflictErr *ConflictError
+	if err != nil && !errors.As(err, &conflictErr) {
+		return nil, reconcileResult{}, fmt.Errorf("identify rolled back: %w", err)
+	}
+
+	// A conflict keeps what was written before it, such as the review queue entry
+	if commitErr := s.commit(tx); commitErr != nil {
+		return nil, reconcileResult{}, fmt.Errorf("failed to commit identify: %w", commitErr)
+	}
+	return response, result, err
+}
+
+// identify reconciles a normalized request; the caller binds the service to a transaction
+func (s *ReconciliationService) identify(req models.IdentifyRequest, opts IdentifyOptions) (*models.IdentifyResponse, reconcileResult, error) {
+	if err := s.recordVerification(req); err != nil {
+		return nil, reconcileResult{}, fmt.Errorf("failed to record verified identifiers: %w", err)
+	}
+
+	// Returning users who send exactly the primary's values need no reconciliation
+	if s.cfg.ExactMatchFastPath && !opts.restoring {
+		primary, err := s.findExactPrimary(req)
+		if err != nil {
+			return nil, reconcileResult{}, fmt.Errorf("failed to look up exact match: %w", err)
+		}
+		if primary != nil {
+			// Answered exactly as the full reconciliation would
+			response, err := s.finishResponse(primary.ID, req, opts)
+			return response, reconcileResult{primaryID: primary.ID, action: ActionNoChange, confidence: 1}, err
+		}
+	}
+
+	if opts.PrimaryStrategy == "" {
+		opts.PrimaryStrategy = s.cfg.PrimaryStrategy
+	}
+
+	result, err := s.reconcile(req, opts)
+	if err != nil {
+		return nil, reconcileResult{}, err
+	}
+
+	// A concurrent writer can leave two primaries in the component. This
+	// transaction's snapshot cannot see that writer, so Identify rolls back and
+	// starts over instead. AND matching keeps primaries that share a single
+	// identifier apart by design.
+	if s.cfg.VerifySinglePrimary && s.cfg.MatchMode != config.MatchModeAnd && !result.keptSeparate {
+		single, err := s.hasSinglePrimary(req)
+		if err != nil {
+			return nil, reconcileResult{}, fmt.Errorf("failed to verify primary invariant: %w", err)
+		}
+		if !single {
+			return nil, reconcileResult{}, errMultiplePrimaries
+		}
+	}
+
+	// Build the response
+	response, err := s.finishResponse(result.primaryID, req, opts)
+	return response, result, err
+}
+
+// Reconciliation actions, as reported in decision events, metrics and the
+// verbose resolution field
+const (
+	ActionCreatedPrimary   = "created_primary"
+	ActionCreatedSecondary = "created_secondary"
+	ActionMerged           = "merged"
+	ActionNoChange         = "no_change"
+)
+
+// reconcileResult describes what a reconciliation pass did
+type reconcileResult struct {
+	primaryID int64
+	action    string
+	// confidence is the share of the request's identifiers already known to the cluster
+	confidence float64
+	// keptSeparate is set when the conflict resolver refused to merge the clusters
+	keptSeparate bool
+}
+
+// reconcile links the request into the contact graph. A request carries at most
+// one email and one phone number, so new identifiers always fit on the single
+// secondary row. opts.PrimaryStrategy picks the primary.
+func (s *ReconciliationService) reconcile(req models.IdentifyRequest, opts IdentifyOptions) (reconcileResult, error) {
+	// Find existing contacts matching email OR phone number
+	linkedContacts, err := s.matchCluster(req, opts, true)
+	if err != nil {
+		return reconcileResult{}, err
+	}
 
 	if len(linkedContacts) == 0 {
 		// No existing contacts - create new primary
-		primaryContact, err = s.createPrimaryContact(req.Email, req.PhoneNumber)
+		primaryContact, err := s.createPrimaryContact(req)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create primary contact: %w", err)
+			return reconcileResult{}, fmt.Errorf("failed to create primary contact: %w", err)
 		}
-	} else {
-		// Find the oldest contact to be the primary
-		primaryContact = s.findOldestContact(linkedContacts)
+		return reconcileResult{primaryID: primaryContact.ID, action: ActionCreatedPrimary}, nil
+	}
 
-		// Check if we need to create a secondary contact
-		hasNewInfo := s.hasNewInformation(linkedContacts, req.Email, req.PhoneNumber)
+	// Hold back requests that would merge two unrelated, established clusters
+	if s.cfg.ConflictPolicy == config.ConflictPolicyFlag {
+		if err := s.checkClusterConflict(req); err != nil {
+			return reconcileResult{}, err
+		}
+	}
 
-		if hasNewInfo {
-			_, err = s.createSecondaryContact(req.Email, req.PhoneNumber, primaryContact.ID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create secondary contact: %w", err)
-			}
+	// Apply the configured resolver's decision on merging existing clusters,
+	// taken before the transaction began
+	decision := DecisionMerge
+	if s.resolver != nil {
+		var primaryIDs []int64
+		decision, primaryIDs, err = opts.resolution.decisionFor(linkedContacts)
+		if err != nil {
+			return reconcileResult{}, err
+		}
+		if decision == DecisionFlag {
+			return reconcileResult{}, s.flagResolvedConflict(req, primaryIDs)
 		}
+	}
+
+	// Pick the primary, by default the oldest contact
+	primaryContact, err := s.selectPrimaryContact(linkedContacts, opts.PrimaryStrategy, req.AccountID)
+	if err != nil {
+		return reconcileResult{}, fmt.Errorf("failed to select primary contact: %w", err)
+	}
+	result := reconcileResult{
+		primaryID:  primaryContact.ID,
+		action:     ActionNoChange,
+		confidence: knownIdentifierShare(linkedContacts, req),
+	}
+
+	// Kept-separate clusters are answered as they are, without linking anything
+	if decision == DecisionKeepSeparate {
+		result.primaryID, err = s.resolvePrimaryID(primaryContact.ID)
+		result.keptSeparate = true
+		return result, err
+	}
+
+	// Check if we need to create a secondary contact
+	hasNewInfo := s.hasNewInformation(linkedContacts, req.Email, req.PhoneNumber)
 
-		// Reconcile primary/secondary status
-		err = s.reconcilePrimaryStatus(linkedContacts, primaryContact.ID)
+	if hasNewInfo {
+		_, err = s.createSecondaryContact(req, primaryContact.ID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to reconcile primary status: %w", err)
+			return reconcileResult{}, fmt.Errorf("failed to create secondary contact: %w", err)
 		}
+		result.action = ActionCreatedSecondary
 	}
 
-	// Build the response
-	return s.buildResponse(primaryContact.ID)
+	// Reconcile primary/secondary status
+	merged, err := s.reconcilePrimaryStatus(linkedContacts, primaryContact.ID, opts.RequestID)
+	if err != nil {
+		return reconcileResult{}, fmt.Errorf("failed to reconcile primary status: %w", err)
+	}
+	if merged {
+		result.action = ActionMerged
+	}
+
+	if s.cfg.CanonicalPromotion {
+		if err := s.promoteCanonicalIdentifiers(primaryContact.ID); err != nil {
+			return reconcileResult{}, fmt.Errorf("failed to promote canonical identifiers: %w", err)
+		}
+	}
+
+	return result, nil
 }
 
-// findLinkedContacts finds all contacts linked by email or phone number
-func (s *ReconciliationService) findLinkedContacts(email, phoneNumber *string) ([]*models.Contact, error) {
-	contactMap := make(map[int64]*models.Contact)
+// matchCluster returns the contacts the request links to, plus extra, with any
+// linked_id cycle re-linked to the selected primary. repair stores that fix;
+// the read-only pass made before the transaction only applies it to the loaded
+// contacts.
+func (s *ReconciliationService) matchCluster(req models.IdentifyRequest, opts IdentifyOptions, repair bool, extra ...*models.Contact) ([]*models.Contact, error) {
+	linkedContacts, err := s.findLinkedContacts(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find linked contacts: %w", err)
+	}
+	linkedContacts = append(linkedContacts, extra...)
 
-	// Query by email
-	if email != nil && *email != "" {
-		contacts, err := s.queryContactsByEmail(*email)
+	// Cycles must be gone before conflict checks and merges resolve primaries
+	if cycles := s.validateCluster(linkedContacts); len(cycles) > 0 {
+		primary, err := s.selectPrimaryContact(linkedContacts, opts.PrimaryStrategy, req.AccountID)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to select primary contact: %w", err)
 		}
-		for _, c := range contacts {
-			contactMap[c.ID] = c
+		if !repair {
+			relinkCycles(linkedContacts, cycles, primary)
+		} else if err := s.repairCycles(linkedContacts, cycles, primary); err != nil {
+			return nil, fmt.Errorf("failed to repair linked_id cycles: %w", err)
 		}
 	}
 
-	// Query by phone number
-	if phoneNumber != nil && *phoneNumber != "" {
-		contacts, err := s.queryContactsByPhoneNumber(*phoneNumber)
+	// In AND mode a request carrying both identifiers only joins when both are known
+	if s.cfg.MatchMode == config.MatchModeAnd && req.Email != nil && req.PhoneNumber != nil &&
+		knownIdentifierShare(linkedContacts, req) < 1 {
+		return nil, nil
+	}
+	return linkedContacts, nil
+}
+
+// finishResponse builds the response for a primary and applies the per-request extras
+func (s *ReconciliationService) finishResponse(primaryID int64, req models.IdentifyRequest, opts IdentifyOptions) (*models.IdentifyResponse, error) {
+	response, err := s.buildResponse(primaryID, opts)
+	if err != nil {
+		return nil, err
+	}
+	applyRequestExtras(response, req, opts, s.cfg.EmptyIdentifierPlaceholder)
+	return response, nil
+}
+
+// applyRequestExtras sets the response's empty-array placeholder and echoed input
+func applyRequestExtras(response *models.IdentifyResponse, req models.IdentifyRequest, opts IdentifyOptions, placeholder string) {
+	// Clients that choke on empty arrays can ask for an explicit marker instead
+	if placeholder != "" {
+		if len(response.Contact.Emails) == 0 {
+			response.Contact.Emails = []string{placeholder}
+		}
+		if len(response.Contact.PhoneNumbers) == 0 {
+			response.Contact.PhoneNumbers = []string{placeholder}
+		}
+	}
+
+	if opts.EchoNormalizedInput {
+		response.NormalizedInput = &models.NormalizedInput{
+			Email:       req.Email,
+			PhoneNumber: req.PhoneNumber,
+		}
+	}
+}
+
+// hasSinglePrimary re-reads the contacts matching the request and reports whether
+// they all resolve to the same primary
+func (s *ReconciliationService) hasSinglePrimary(req models.IdentifyRequest) (bool, error) {
+	// Shared phones link to a single cluster by design under email-authoritative matching
+	useEmail, usePhone := s.linkingIdentifiers(req)
+	if s.cfg.MatchMode == config.MatchModeEmail && usePhone {
+		return true, nil
+	}
+
+	var matches []*models.Contact
+	if useEmail {
+		contacts, err := s.queryContactsByEmail(*req.Email, req.AccountID)
 		if err != nil {
-			return nil, err
+			return false, err
 		}
-		for _, c := range contacts {
-			contactMap[c.ID] = c
+		matches = append(matches, contacts...)
+	}
+	if usePhone {
+		contacts, err := s.queryContactsByPhoneNumber(*req.PhoneNumber, req.AccountID)
+		if err != nil {
+			return false, err
+		}
+		matches = append(matches, contacts...)
+	}
+
+	primaries := make(map[int64]bool)
+	for _, c := range matches {
+		primaryID, err := s.resolvePrimaryID(c.ID)
+		if err != nil {
+			return false, err
 		}
+		primaries[primaryID] = true
+	}
+	return len(primaries) <= 1, nil
+}
+
+// findExactPrimary returns the primary whose stored email and phone number both
+// equal the request's, using a single indexed lookup
+func (s *ReconciliationService) findExactPrimary(req models.IdentifyRequest) (*models.Contact, error) {
+	var candidates []*models.Contact
+	var err error
+
+	switch {
+	case req.Email != nil:
+		query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
+				  FROM contacts WHERE email = $1 AND COALESCE(account_id, '') = $2 AND link_precedence = 'primary' AND deleted_at IS NULL`
+		candidates, err = s.queryContacts(query, *req.Email, accountKey(req.AccountID))
+	case req.PhoneNumber != nil:
+		query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
+				  FROM contacts WHERE phone_number = $1 AND COALESCE(account_id, '') = $2 AND link_precedence = 'primary' AND deleted_at IS NULL`
+		candidates, err = s.queryContacts(query, *req.PhoneNumber, accountKey(req.AccountID))
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Also find contacts linked via linked_id
-	allLinkedIDs := make(map[int64]bool)
-	for _, c := range contactMap {
-		if c.LinkedID != nil {
-			allLinkedIDs[*c.LinkedID] = true
+	for _, c := range candidates {
+		if equalStringPtr(c.Email, req.Email) && equalStringPtr(c.PhoneNumber, req.PhoneNumber) {
+			return c, nil
 		}
 	}
+	return nil, nil
+}
+
+// findLinkedContacts finds all contacts linked by email or phone number, expanded
+// to the full connected component of linked_id edges so that merging clusters
+// re-points every member, however deep its chain
+func (s *ReconciliationService) findLinkedContacts(req models.IdentifyRequest) ([]*models.Contact, error) {
+	var matches []*models.Contact
+	useEmail, usePhone := s.linkingIdentifiers(req)
 
-	for linkedID := range allLinkedIDs {
-		linkedContacts, err := s.queryContactsByLinkedID(linkedID)
+	// Query by email
+	if useEmail {
+		contacts, err := s.queryContactsByEmail(*req.Email, req.AccountID)
 		if err != nil {
 			return nil, err
 		}
-		for _, c := range linkedContacts {
-			contactMap[c.ID] = c
+		matches = append(matches, contacts...)
+	}
+
+	// Query by phone number
+	if usePhone {
+		contacts, err := s.queryContactsByPhoneNumber(*req.PhoneNumber, req.AccountID)
+		if err != nil {
+			return nil, err
 		}
+		matches = append(matches, contacts...)
 	}
 
-	// Convert map to slice
-	result := make([]*models.Contact, 0, len(contactMap))
-	for _, c := range contactMap {
-		result = append(result, c)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	seedIDs := make([]int64, 0, len(matches))
+	for _, c := range matches {
+		seedIDs = append(seedIDs, c.ID)
+	}
+	component, err := s.queryComponent(seedIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	// A shared phone never merges the clusters it appears in; it only joins the oldest
+	if s.cfg.MatchMode == config.MatchModeEmail && usePhone {
+		component = s.oldestCluster(component)
+	}
+	return component, nil
+}
+
+// linkingIdentifiers reports which request identifiers may link it to existing
+// contacts. Identifiers over the join velocity are treated as shared, and under
+// email-authoritative matching a phone number only links requests without an email.
+func (s *ReconciliationService) linkingIdentifiers(req models.IdentifyRequest) (useEmail, usePhone bool) {
+	emailBlocked, phoneBlocked := s.linkBlocked(req)
+	hasEmail := req.Email != nil && *req.Email != ""
+	useEmail = hasEmail && !emailBlocked
+	usePhone = req.PhoneNumber != nil && *req.PhoneNumber != "" && !phoneBlocked
+	if s.cfg.MatchMode == config.MatchModeEmail && hasEmail {
+		usePhone = false
+	}
+	return useEmail, usePhone
+}
+
+// oldestCluster keeps the members of the component's oldest primary, grouping
+// contacts by the root their linked_id chain ends at
+func (s *ReconciliationService) oldestCluster(component []*models.Contact) []*models.Contact {
+	byID := make(map[int64]*models.Contact, len(component))
+	var roots []*models.Contact
+	for _, c := range component {
+		byID[c.ID] = c
+		if c.LinkedID == nil {
+			roots = append(roots, c)
+		}
+	}
+	if len(roots) < 2 {
+		return component
+	}
+	oldest := s.findOldestContact(roots)
+
+	var cluster []*models.Contact
+	for _, c := range component {
+		root, hops := c, 0
+		for root.LinkedID != nil && byID[*root.LinkedID] != nil && hops < len(component) {
+			root, hops = byID[*root.LinkedID], hops+1
+		}
+		if root.ID == oldest.ID {
+			cluster = append(cluster, c)
+		}
+	}
+	return cluster
 }
 
-// queryContactsByEmail queries contacts by email
-func (s *ReconciliationService) queryContactsByEmail(email string) ([]*models.Contact, error) {
+// queryComponent returns the active contacts connected to the seed contacts
+// through linked_id in either direction, at any depth, in a single recursive
+// query. The statement is plain SQL:1999 and runs unchanged on SQLite and
+// PostgreSQL; UNION drops revisited rows so corrupted cycles terminate.
+func (s *ReconciliationService) queryComponent(seedIDs []int64) ([]*models.Contact, error) {
+	placeholders := make([]string, len(seedIDs))
+	args := make([]any, len(seedIDs))
+	for i, id := range seedIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	query := `WITH RECURSIVE component(id) AS (
+				SELECT id FROM contacts WHERE id IN (` + strings.Join(placeholders, ", ") + `) AND deleted_at IS NULL
+				UNION
+				SELECT c.id FROM component k 
+				JOIN contacts p ON p.id = k.id 
+				JOIN contacts c ON c.linked_id = p.id OR c.id = p.linked_id 
+				WHERE c.deleted_at IS NULL
+			  )
+			  SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
+			  FROM contacts WHERE id IN (SELECT id FROM component)`
+	return s.queryContacts(query, args...)
+}
+
+// queryContactsByEmail queries contacts by email within an account scope
+func (s *ReconciliationService) queryContactsByEmail(email string, accountID *string) ([]*models.Contact, error) {
+	key, _, canonical := s.emailMatchKey(email)
+	if !canonical {
+		query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
+				  FROM contacts WHERE email = $1 AND COALESCE(account_id, '') = $2 AND deleted_at IS NULL`
+		return s.queryContacts(query, email, accountKey(accountID))
+	}
+
+	// Provider-canonical emails can differ in case, dots and tags, so compare
+	// the match keys stored next to them
 	query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
-			  FROM contacts WHERE email = $1 AND deleted_at IS NULL`
-	return s.queryContacts(query, email)
+			  FROM contacts WHERE email_match_key = $1 AND COALESCE(account_id, '') = $2 AND deleted_at IS NULL`
+	return s.queryContacts(query, key, accountKey(accountID))
 }
 
-// queryContactsByPhoneNumber queries contacts by phone number
-func (s *ReconciliationService) queryContactsByPhoneNumber(phone string) ([]*models.Contact, error) {
+// queryContactsByPhoneNumber queries contacts by phone number within an account scope
+func (s *ReconciliationService) queryContactsByPhoneNumber(phone string, accountID *string) ([]*models.Contact, error) {
 	query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
-			  FROM contacts WHERE phone_number = $1 AND deleted_at IS NULL`
-	return s.queryContacts(query, phone)
+			  FROM contacts WHERE phone_number = $1 AND COALESCE(account_id, '') = $2 AND deleted_at IS NULL`
+	return s.queryContacts(query, phone, accountKey(accountID))
+}
+
+// accountKey maps an optional account scope to the value compared against
+// COALESCE(account_id, ”); unscoped requests only see unscoped contacts
+func accountKey(accountID *string) string {
+	if accountID == nil {
+		return ""
+	}
+	return *accountID
 }
 
 // queryContactsByLinkedID queries contacts by linked_id
@@ -135,9 +718,10 @@ func (s *ReconciliationService) queryContactsByLinkedID(linkedID int64) ([]*mode
 	return s.queryContacts(query, linkedID)
 }
 
-// queryContacts executes a query and returns contacts
+// queryContacts executes a query and returns contacts. On error the contacts read
+// so far are returned along with it.
 func (s *ReconciliationService) queryContacts(query string, args ...interface{}) ([]*models.Contact, error) {
-	rows, err := s.db.Conn.Query(query, args...)
+	rows, err := s.conn.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -145,49 +729,127 @@ func (s *ReconciliationService) queryContacts(query string, args ...interface{})
 
 	var contacts []*models.Contact
 	for rows.Next() {
-		c := &models.Contact{}
-		var phone, email sql.NullString
-		var linkedID sql.NullInt64
-		var deletedAt sql.NullTime
-
-		err := rows.Scan(&c.ID, &phone, &email, &linkedID, &c.LinkPrecedence, &c.CreatedAt, &c.UpdatedAt, &deletedAt)
+		c, err := scanContact(rows)
 		if err != nil {
-			return nil, err
+			return contacts, err
 		}
-
-		if phone.Valid {
-			c.PhoneNumber = &phone.String
-		}
-		if email.Valid {
-			c.Email = &email.String
-		}
-		if linkedID.Valid {
-			c.LinkedID = &linkedID.Int64
-		}
-		if deletedAt.Valid {
-			c.DeletedAt = &deletedAt.Time
-		}
-
 		contacts = append(contacts, c)
 	}
 
 	return contacts, rows.Err()
 }
 
+// scanContact reads a row of id, phone_number, email, linked_id,
+// link_precedence, created_at, updated_at, deleted_at
+func scanContact(rows *sql.Rows) (*models.Contact, error) {
+	c := &models.Contact{}
+	var phone, email sql.NullString
+	var linkedID sql.NullInt64
+	var deletedAt sql.NullTime
+
+	err := rows.Scan(&c.ID, &phone, &email, &linkedID, &c.LinkPrecedence, &c.CreatedAt, &c.UpdatedAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if phone.Valid {
+		c.PhoneNumber = &phone.String
+	}
+	if email.Valid {
+		c.Email = &email.String
+	}
+	if linkedID.Valid {
+		c.LinkedID = &linkedID.Int64
+	}
+	if deletedAt.Valid {
+		c.DeletedAt = &deletedAt.Time
+	}
+	return c, nil
+}
+
+// selectPrimaryContact picks the contact that should be primary under the strategy
+func (s *ReconciliationService) selectPrimaryContact(contacts []*models.Contact, strategy string, accountID *string) (*models.Contact, error) {
+	switch {
+	case len(contacts) == 0:
+		return nil, nil
+	case strategy == config.PrimaryStrategyLowestID:
+		lowest := contacts[0]
+		for _, c := range contacts[1:] {
+			if c.ID < lowest.ID {
+				lowest = c
+			}
+		}
+		return lowest, nil
+	case strategy == config.PrimaryStrategyVerified:
+		// The oldest contact carrying a verified identifier, else the oldest
+		sortContactsByCreation(contacts)
+		for _, c := range contacts {
+			verified, err := s.hasVerifiedIdentifier(c, accountID)
+			if err != nil {
+				return nil, err
+			}
+			if verified {
+				return c, nil
+			}
+		}
+	}
+	return s.findOldestContact(contacts), nil
+}
+
 // findOldestContact finds the oldest contact in the list
 func (s *ReconciliationService) findOldestContact(contacts []*models.Contact) *models.Contact {
 	if len(contacts) == 0 {
 		return nil
 	}
 
+	sortContactsByCreation(contacts)
+
+	return contacts[0]
+}
+
+// sortContactsByCreation orders contacts oldest first, using the id as tiebreaker
+func sortContactsByCreation(contacts []*models.Contact) {
 	sort.Slice(contacts, func(i, j int) bool {
-		return contacts[i].CreatedAt.Before(contacts[j].CreatedAt)
+		if !contacts[i].CreatedAt.Equal(contacts[j].CreatedAt) {
+			return contacts[i].CreatedAt.Before(contacts[j].CreatedAt)
+		}
+		return contacts[i].ID < contacts[j].ID
 	})
+}
 
-	return contacts[0]
+// knownIdentifierShare returns the fraction of the request's identifiers that the
+// matched contacts already carry
+func knownIdentifierShare(contacts []*models.Contact, req models.IdentifyRequest) float64 {
+	provided, known := 0, 0
+	if req.Email != nil {
+		provided++
+		for _, c := range contacts {
+			if equalStringPtr(c.Email, req.Email) {
+				known++
+				break
+			}
+		}
+	}
+	if req.PhoneNumber != nil {
+		provided++
+		for _, c := range contacts {
+			if equalStringPtr(c.PhoneNumber, req.PhoneNumber) {
+				known++
+				break
+			}
+		}
+	}
+	if provided == 0 {
+		return 0
+	}
+	return float64(known) / float64(provided)
 }
 
-// hasNewInformation checks if the request contains new email or phone number
+// hasNewInformation reports whether the request's exact email or phone number
+// is absent from every contact of the cluster it joins. Identifiers spread over
+// different contacts still count as known, so an email on one contact and a
+// phone number on another never produce a secondary combining them. contacts
+// must be the whole cluster, as returned by findLinkedContacts.
 func (s *ReconciliationService) hasNewInformation(contacts []*models.Contact, email, phoneNumber *string) bool {
 	existingEmails := make(map[string]bool)
 	existingPhones := make(map[string]bool)
@@ -215,98 +877,234 @@ func (s *ReconciliationService) hasNewInformation(contacts []*models.Contact, em
 }
 
 // createPrimaryContact creates a new primary contact
-func (s *ReconciliationService) createPrimaryContact(email, phoneNumber *string) (*models.Contact, error) {
-	query := `INSERT INTO contacts (phone_number, email, link_precedence, created_at, updated_at) 
-			  VALUES ($1, $2, 'primary', $3, $4) RETURNING id`
-
-	now := time.Now()
-	var id int64
-	err := s.db.Conn.QueryRow(query, phoneNumber, email, now, now).Scan(&id)
-	if err != nil {
-		return nil, err
-	}
-
-	return &models.Contact{
-		ID:             id,
-		PhoneNumber:    phoneNumber,
-		Email:          email,
-		LinkPrecedence: "primary",
-		CreatedAt:      now,
-		UpdatedAt:      now,
-	}, nil
+func (s *ReconciliationService) createPrimaryContact(req models.IdentifyRequest) (*models.Contact, error) {
+	return s.insertContact(req, nil, "primary")
 }
 
 // createSecondaryContact creates a new secondary contact
-func (s *ReconciliationService) createSecondaryContact(email, phoneNumber *string, linkedID int64) (*models.Contact, error) {
-	query := `INSERT INTO contacts (phone_number, email, linked_id, link_precedence, created_at, updated_at) 
-			  VALUES ($1, $2, $3, 'secondary', $4, $5) RETURNING id`
+func (s *ReconciliationService) createSecondaryContact(req models.IdentifyRequest, linkedID int64) (*models.Contact, error) {
+	return s.insertContact(req, &linkedID, "secondary")
+}
 
+// insertContact inserts a contact, using the id generator when it allocates ids
+// and the database's auto-increment otherwise
+func (s *ReconciliationService) insertContact(req models.IdentifyRequest, linkedID *int64, precedence string) (*models.Contact, error) {
+	email, phoneNumber := req.Email, req.PhoneNumber
 	now := time.Now()
-	var id int64
-	err := s.db.Conn.QueryRow(query, phoneNumber, email, linkedID, now, now).Scan(&id)
+	id := s.idGen.NextID()
+
+	var err error
+	matchKey := s.storedEmailMatchKey(email)
+	if id == 0 {
+		query := `INSERT INTO contacts (phone_number, email, email_match_key, account_id, linked_id, link_precedence, created_at, updated_at) 
+				  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+		err = s.conn.QueryRow(query, phoneNumber, email, matchKey, req.AccountID, linkedID, precedence, now, now).Scan(&id)
+	} else {
+		query := `INSERT INTO contacts (id, phone_number, email, email_match_key, account_id, linked_id, link_precedence, created_at, updated_at) 
+				  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+		_, err = s.conn.Exec(query, id, phoneNumber, email, matchKey, req.AccountID, linkedID, precedence, now, now)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// The identifiers exist now, so cached absences are stale
+	s.stale.forgetAbsent(s.identifierCacheKeys(req)...)
+
 	return &models.Contact{
 		ID:             id,
 		PhoneNumber:    phoneNumber,
 		Email:          email,
-		LinkedID:       &linkedID,
-		LinkPrecedence: "secondary",
+		AccountID:      req.AccountID,
+		LinkedID:       linkedID,
+		LinkPrecedence: precedence,
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}, nil
 }
 
+// identifierCacheKeys returns the match cache keys of a request's identifiers
+func (s *ReconciliationService) identifierCacheKeys(req models.IdentifyRequest) []string {
+	var keys []string
+	if req.Email != nil {
+		key, _, _ := s.emailMatchKey(*req.Email)
+		keys = append(keys, matchCacheKey("email", key, req.AccountID))
+	}
+	if req.PhoneNumber != nil {
+		keys = append(keys, matchCacheKey("phone", *req.PhoneNumber, req.AccountID))
+	}
+	return keys
+}
+
 // reconcilePrimaryStatus ensures the oldest contact is primary and others are secondary
-func (s *ReconciliationService) reconcilePrimaryStatus(contacts []*models.Contact, primaryID int64) error {
+// and reports whether another primary was demoted (i.e. two clusters merged)
+func (s *ReconciliationService) reconcilePrimaryStatus(contacts []*models.Contact, primaryID int64, requestID string) (bool, error) {
+	// Promote the primary first so the others resolve to it rather than to its old root
 	for _, c := range contacts {
-		if c.ID == primaryID {
-			// This should be primary
-			if c.LinkPrecedence != "primary" {
-				err := s.updateContactPrecedence(c.ID, "primary", nil)
-				if err != nil {
-					return err
-				}
+		if c.ID == primaryID && c.LinkPrecedence != "primary" {
+			if err := s.updateContactPrecedence(c.ID, "primary", nil); err != nil {
+				return false, err
 			}
-		} else {
-			// This should be secondary
-			if c.LinkPrecedence != "secondary" || c.LinkedID == nil || *c.LinkedID != primaryID {
-				err := s.updateContactPrecedence(c.ID, "secondary", &primaryID)
-				if err != nil {
-					return err
-				}
+		}
+	}
+
+	merged := false
+	for _, c := range contacts {
+		// Everything else should be a secondary of the primary
+		if c.ID == primaryID || (c.LinkPrecedence == "secondary" && c.LinkedID != nil && *c.LinkedID == primaryID) {
+			continue
+		}
+		if err := s.updateContactPrecedence(c.ID, "secondary", &primaryID); err != nil {
+			return false, err
+		}
+
+		// A demoted primary means its whole cluster was absorbed
+		if c.LinkPrecedence == "primary" {
+			if err := s.recordMerge(c.ID, primaryID); err != nil {
+				return false, err
 			}
+			s.reportDemotion(c.ID, primaryID, requestID)
+			merged = true
 		}
 	}
-	return nil
+	return merged, s.flattenSecondaryChains(primaryID)
 }
 
-// updateContactPrecedence updates a contact's link_precedence and linked_id
+// maxFlattenPasses caps flattenSecondaryChains; each pass shortens every chain
+// by one link, so only corrupted data gets near it
+const maxFlattenPasses = 64
+
+// flattenSecondaryChains re-points contacts linked to one of the primary's
+// secondaries directly at the primary, so no secondary -> secondary chain survives
+func (s *ReconciliationService) flattenSecondaryChains(primaryID int64) error {
+	// A primary linked to itself would count as its own secondary below
+	unlink := `UPDATE contacts SET linked_id = NULL, updated_at = $1 WHERE id = $2 AND linked_id = $3`
+	if _, err := s.conn.Exec(unlink, time.Now(), primaryID, primaryID); err != nil {
+		return fmt.Errorf("failed to clear self-link of primary %d: %w", primaryID, err)
+	}
+
+	query := `UPDATE contacts SET linked_id = $1, link_precedence = 'secondary', updated_at = $2 
+			  WHERE linked_id IN (SELECT id FROM contacts WHERE linked_id = $3 AND id <> $4) AND id <> $5`
+	// Repeat until no chain is left so descendants at any depth are re-pointed
+	for range maxFlattenPasses {
+		result, err := s.conn.Exec(query, primaryID, time.Now(), primaryID, primaryID, primaryID)
+		if err != nil {
+			return fmt.Errorf("failed to flatten secondary chains: %w", err)
+		}
+
+		flattened, err := result.RowsAffected()
+		if err != nil || flattened == 0 {
+			return nil
+		}
+		log.Printf("Flattened %d secondary -> secondary links onto primary %d", flattened, primaryID)
+	}
+	return fmt.Errorf("secondary chains of primary %d are still not flat after %d passes", primaryID, maxFlattenPasses)
+}
+
+// updateContactPrecedence updates a contact's link_precedence and linked_id.
+// A secondary is always linked to the true primary of the chain, never to another secondary.
 func (s *ReconciliationService) updateContactPrecedence(id int64, precedence string, linkedID *int64) error {
+	if linkedID != nil {
+		rootID, err := s.resolvePrimaryID(*linkedID)
+		if err != nil {
+			return err
+		}
+		if rootID == id {
+			return fmt.Errorf("refusing to link contact %d to itself", id)
+		}
+		linkedID = &rootID
+	}
+
 	query := `UPDATE contacts SET link_precedence = $1, linked_id = $2, updated_at = $3 WHERE id = $4`
-	_, err := s.db.Conn.Exec(query, precedence, linkedID, time.Now(), id)
-	return err
+	if _, err := s.conn.Exec(query, precedence, linkedID, time.Now(), id); err != nil {
+		return err
+	}
+
+	// Cached primaries may point at a contact that just changed role
+	s.stale.resetPositive()
+	return nil
+}
+
+// resolvePrimaryID follows linked_id pointers from a contact up to its primary
+func (s *ReconciliationService) resolvePrimaryID(id int64) (int64, error) {
+	visited := make(map[int64]bool)
+	currentID := id
+
+	for {
+		if visited[currentID] {
+			return 0, fmt.Errorf("linked_id cycle detected at contact %d", currentID)
+		}
+		visited[currentID] = true
+
+		var precedence string
+		var linkedID sql.NullInt64
+		query := `SELECT link_precedence, linked_id FROM contacts WHERE id = $1`
+		if err := s.conn.QueryRow(query, currentID).Scan(&precedence, &linkedID); err != nil {
+			return 0, fmt.Errorf("failed to resolve primary of contact %d: %w", id, err)
+		}
+
+		if !linkedID.Valid {
+			if precedence != "primary" {
+				return 0, fmt.Errorf("contact %d has no linked_id but is not a primary", currentID)
+			}
+			return currentID, nil
+		}
+		currentID = linkedID.Int64
+	}
 }
 
 // buildResponse builds the identify response for a primary contact
-func (s *ReconciliationService) buildResponse(primaryID int64) (*models.IdentifyResponse, error) {
+func (s *ReconciliationService) buildResponse(primaryID int64, opts IdentifyOptions) (*models.IdentifyResponse, error) {
 	// Get all linked contacts (primary + secondaries)
 	allContacts, err := s.getAllLinkedContacts(primaryID)
+	partial := false
+	if err != nil {
+		if !s.cfg.PartialResponses {
+			return nil, err
+		}
+		log.Printf("Returning partial cluster for primary %d: %v", primaryID, err)
+		if allContacts, err = s.salvageCluster(primaryID, allContacts); err != nil {
+			return nil, err
+		}
+		partial = true
+	}
+
+	verified, err := s.clusterVerification(primaryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load verified identifiers: %w", err)
+	}
+	response, err := s.assembleResponse(primaryID, allContacts, verified, opts)
 	if err != nil {
 		return nil, err
 	}
+	response.Partial = partial
+	return response, nil
+}
+
+// assembleResponse builds the identify response from a primary's active
+// cluster and its verified identifiers
+func (s *ReconciliationService) assembleResponse(primaryID int64, allContacts []*models.Contact, verified map[string]bool, opts IdentifyOptions) (*models.IdentifyResponse, error) {
+	// Secondaries are reported in order of creation, ties broken by id, so the
+	// response does not depend on row or map order
+	sort.SliceStable(allContacts, func(i, j int) bool {
+		a, b := allContacts[i], allContacts[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
 
 	emails := []string{}
 	phoneNumbers := []string{}
 	secondaryContactIDs := []int64{}
 	primaryEmail := ""
 	primaryPhone := ""
+	var primary *models.Contact
 
 	// Find primary contact details first
 	for _, c := range allContacts {
 		if c.ID == primaryID {
+			primary = c
 			if c.Email != nil {
 				primaryEmail = *c.Email
 			}
@@ -318,6 +1116,10 @@ func (s *ReconciliationService) buildResponse(primaryID int64) (*models.Identify
 		}
 	}
 
+	// A contact reached twice while assembling the cluster is listed once
+	slices.Sort(secondaryContactIDs)
+	secondaryContactIDs = slices.Compact(secondaryContactIDs)
+
 	// Add primary email and phone first
 	if primaryEmail != "" {
 		emails = append(emails, primaryEmail)
@@ -326,35 +1128,125 @@ func (s *ReconciliationService) buildResponse(primaryID int64) (*models.Identify
 		phoneNumbers = append(phoneNumbers, primaryPhone)
 	}
 
-	// Collect unique emails and phone numbers from all contacts
-	emailSet := make(map[string]bool)
-	phoneSet := make(map[string]bool)
-
+	// Then the other unique emails and phone numbers, oldest contact first
+	emailSeen := map[string]bool{primaryEmail: true}
+	phoneSeen := map[string]bool{primaryPhone: true}
 	for _, c := range allContacts {
-		if c.Email != nil && *c.Email != "" && *c.Email != primaryEmail {
-			emailSet[*c.Email] = true
+		if c.Email != nil && !emailSeen[*c.Email] {
+			emailSeen[*c.Email] = true
+			emails = append(emails, *c.Email)
 		}
-		if c.PhoneNumber != nil && *c.PhoneNumber != "" && *c.PhoneNumber != primaryPhone {
-			phoneSet[*c.PhoneNumber] = true
+		if c.PhoneNumber != nil && !phoneSeen[*c.PhoneNumber] {
+			phoneSeen[*c.PhoneNumber] = true
+			phoneNumbers = append(phoneNumbers, *c.PhoneNumber)
 		}
 	}
 
-	// Add secondary emails and phones
-	for email := range emailSet {
-		emails = append(emails, email)
-	}
-	for phone := range phoneSet {
-		phoneNumbers = append(phoneNumbers, phone)
-	}
+	// Verified identifiers are surfaced first
+	verifiedEmails := orderVerifiedFirst(emails, identifierEmail, verified)
+	verifiedPhones := orderVerifiedFirst(phoneNumbers, identifierPhone, verified)
+
+	clusterCreatedAt, clusterUpdatedAt := clusterTimestamps(allContacts)
 
-	return &models.IdentifyResponse{
+	response := &models.IdentifyResponse{
 		Contact: models.ContactResponse{
-			PrimaryContactID:    primaryID,
-			Emails:              emails,
-			PhoneNumbers:        phoneNumbers,
-			SecondaryContactIDs: secondaryContactIDs,
+			PrimaryContactID:     primaryID,
+			Emails:               emails,
+			PhoneNumbers:         phoneNumbers,
+			SecondaryContactIDs:  secondaryContactIDs,
+			VerifiedEmails:       verifiedEmails,
+			VerifiedPhoneNumbers: verifiedPhones,
+			ClusterCreatedAt:     clusterCreatedAt,
+			ClusterUpdatedAt:     clusterUpdatedAt,
 		},
-	}, nil
+	}
+
+	// Historical identifiers are those missing from every page, so they are
+	// collected before paging
+	if opts.IncludeHistorical {
+		if err := s.addHistoricalIdentifiers(&response.Contact, primaryID); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Limit > 0 || opts.Offset > 0 {
+		pageContact(&response.Contact, primaryEmail, primaryPhone, opts.Offset, opts.Limit)
+	}
+
+	// A partial cluster may lack the primary row
+	if opts.Timestamps && primary != nil {
+		response.Contact.CreatedAt = &primary.CreatedAt
+		response.Contact.UpdatedAt = &primary.UpdatedAt
+	}
+
+	return response, nil
+}
+
+// salvageCluster completes the members read before a failed cluster query with
+// the primary itself, so a degraded response still names the right identity
+func (s *ReconciliationService) salvageCluster(primaryID int64, members []*models.Contact) ([]*models.Contact, error) {
+	for _, c := range members {
+		if c.ID == primaryID {
+			return members, nil
+		}
+	}
+
+	query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
+			  FROM contacts WHERE id = $1 AND deleted_at IS NULL`
+	primary, err := s.queryContacts(query, primaryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load primary %d: %w", primaryID, err)
+	}
+	return append(primary, members...), nil
+}
+
+// clusterTimestamps returns the earliest created_at and latest updated_at of the
+// contacts. They are compared as parsed times rather than with SQL MIN/MAX, which
+// would compare SQLite's text timestamps lexically.
+func clusterTimestamps(contacts []*models.Contact) (createdAt, updatedAt time.Time) {
+	for _, c := range contacts {
+		if createdAt.IsZero() || c.CreatedAt.Before(createdAt) {
+			createdAt = c.CreatedAt
+		}
+		if c.UpdatedAt.After(updatedAt) {
+			updatedAt = c.UpdatedAt
+		}
+	}
+	return createdAt, updatedAt
+}
+
+// addHistoricalIdentifiers collects emails and phone numbers that only exist on
+// soft-deleted members of the cluster
+func (s *ReconciliationService) addHistoricalIdentifiers(resp *models.ContactResponse, primaryID int64) error {
+	deletedContacts, err := s.getDeletedLinkedContacts(primaryID)
+	if err != nil {
+		return err
+	}
+
+	activeEmails := make(map[string]bool)
+	activePhones := make(map[string]bool)
+	for _, email := range resp.Emails {
+		activeEmails[email] = true
+	}
+	for _, phone := range resp.PhoneNumbers {
+		activePhones[phone] = true
+	}
+
+	resp.HistoricalEmails = []string{}
+	resp.HistoricalPhoneNumbers = []string{}
+
+	for _, c := range deletedContacts {
+		if c.Email != nil && *c.Email != "" && !activeEmails[*c.Email] {
+			activeEmails[*c.Email] = true
+			resp.HistoricalEmails = append(resp.HistoricalEmails, *c.Email)
+		}
+		if c.PhoneNumber != nil && *c.PhoneNumber != "" && !activePhones[*c.PhoneNumber] {
+			activePhones[*c.PhoneNumber] = true
+			resp.HistoricalPhoneNumbers = append(resp.HistoricalPhoneNumbers, *c.PhoneNumber)
+		}
+	}
+
+	return nil
 }
 
 // getAllLinkedContacts gets the primary contact and all secondary contacts
@@ -365,3 +1257,173 @@ func (s *ReconciliationService) getAllLinkedContacts(primaryID int64) ([]*models
 
 	return s.queryContacts(query, primaryID, primaryID)
 }
+
+// getDeletedLinkedContacts gets the soft-deleted members of a primary's cluster
+func (s *ReconciliationService) getDeletedLinkedContacts(primaryID int64) ([]*models.Contact, error) {
+	query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
+			  FROM contacts 
+			  WHERE (id = $1 OR linked_id = $2) AND deleted_at IS NOT NULL
+			  ORDER BY created_at, id`
+
+	return s.queryContacts(query, primaryID, primaryID)
+}
+
+// FindPrimaryID returns the primary contact id owning the given email or phone number.
+// The boolean is false when no active contact carries the identifier.
+func (s *ReconciliationService) FindPrimaryID(ctx context.Context, email, phoneNumber, accountID *string) (int64, bool, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	req, err := s.normalize(models.IdentifyRequest{Email: email, PhoneNumber: phoneNumber, AccountID: accountID})
+	if err != nil {
+		return 0, false, err
+	}
+
+	cacheKey := s.lookupCacheKey(req)
+	if primaryID, found, cached := s.matches.lookup(cacheKey); cached {
+		return primaryID, found, nil
+	}
+
+	var matches []*models.Contact
+	switch {
+	case req.Email != nil:
+		matches, err = s.queryContactsByEmail(*req.Email, req.AccountID)
+	case req.PhoneNumber != nil:
+		matches, err = s.queryContactsByPhoneNumber(*req.PhoneNumber, req.AccountID)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if len(matches) == 0 {
+		s.matches.storeAbsent(cacheKey)
+		return 0, false, nil
+	}
+
+	primaryID, err := s.resolvePrimaryID(matches[0].ID)
+	if err != nil {
+		return 0, false, err
+	}
+	s.matches.storeFound(cacheKey, primaryID)
+	return primaryID, true, nil
+}
+
+// Stats counts contacts by precedence and deletion and measures the largest
+// active cluster. Secondaries link straight to their primary (see
+// flattenSecondaryChains), so a cluster is the contacts sharing COALESCE(linked_id, id).
+func (s *ReconciliationService) Stats(ctx context.Context) (*models.ContactStats, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var stats models.ContactStats
+	counts := `
+SELECT COUNT(*),
+       COALESCE(SUM(CASE WHEN deleted_at IS NULL AND link_precedence = 'primary' THEN 1 ELSE 0 END), 0),
+       COALESCE(SUM(CASE WHEN deleted_at IS NULL AND link_precedence = 'secondary' THEN 1 ELSE 0 END), 0),
+       COALESCE(SUM(CASE WHEN deleted_at IS NOT NULL THEN 1 ELSE 0 END), 0)
+FROM contacts`
+	err := s.conn.QueryRow(counts).Scan(&stats.TotalContacts, &stats.Primaries, &stats.Secondaries, &stats.Deleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count contacts: %w", err)
+	}
+
+	largest := `
+SELECT COALESCE(MAX(size), 0) FROM (
+    SELECT COUNT(*) AS size FROM contacts WHERE deleted_at IS NULL GROUP BY COALESCE(linked_id, id)
+) clusters`
+	if err := s.conn.QueryRow(largest).Scan(&stats.LargestClusterSize); err != nil {
+		return nil, fmt.Errorf("failed to measure largest cluster: %w", err)
+	}
+	return &stats, nil
+}
+
+// CountContacts returns the number of active contacts
+func (s *ReconciliationService) CountContacts(ctx context.Context) (int64, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+
+	var n int64
+	if err := s.conn.QueryRow(`SELECT COUNT(*) FROM contacts WHERE deleted_at IS NULL`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count contacts: %w", err)
+	}
+	return n, nil
+}
+
+// GetByID returns the reconciled identity of an active contact, resolving a
+// secondary to its primary; unknown and soft-deleted ids are not found
+func (s *ReconciliationService) GetByID(ctx context.Context, id int64) (*models.IdentifyResponse, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	var deletedAt sql.NullTime
+	err := s.conn.QueryRow(`SELECT deleted_at FROM contacts WHERE id = $1`, id).Scan(&deletedAt)
+	if errors.Is(err, sql.ErrNoRows) || deletedAt.Valid {
+		return nil, ErrContactNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load contact %d: %w", id, err)
+	}
+
+	return s.Cluster(ctx, id, IdentifyOptions{})
+}
+
+// Cluster returns the consolidated view of the cluster containing any contact,
+// primary or secondary, for callers that only know a contact ID
+func (s *ReconciliationService) Cluster(ctx context.Context, id int64, opts IdentifyOptions) (*models.IdentifyResponse, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	primaryID, err := s.resolvePrimaryID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrContactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.finishResponse(primaryID, models.IdentifyRequest{}, IdentifyOptions{IncludeHistorical: opts.IncludeHistorical})
+}
+
+// CRMRecord flattens the cluster containing a contact into a single record. The
+// canonical email and phone are the first values of the consolidated response
+// (verified values, then the primary's); the others become alternates.
+func (s *ReconciliationService) CRMRecord(ctx context.Context, id int64) (*models.CRMRecord, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	primaryID, err := s.resolvePrimaryID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrContactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.buildResponse(primaryID, IdentifyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	contact := response.Contact
+
+	record := &models.CRMRecord{
+		ID:              primaryID,
+		FirstSeen:       contact.ClusterCreatedAt,
+		LastSeen:        contact.ClusterUpdatedAt,
+		AlternateEmails: []string{},
+		AlternatePhones: []string{},
+	}
+	if len(contact.Emails) > 0 {
+		record.Email = &contact.Emails[0]
+		record.AlternateEmails = append(record.AlternateEmails, contact.Emails[1:]...)
+	}
+	if len(contact.PhoneNumbers) > 0 {
+		record.Phone = &contact.PhoneNumbers[0]
+		record.AlternatePhones = append(record.AlternatePhones, contact.PhoneNumbers[1:]...)
+	}
+	return record, nil
+}
+
+// lookupCacheKey returns the match cache key of the identifier FindPrimaryID
+// searches by; emails use their provider match key
+func (s *ReconciliationService) lookupCacheKey(req models.IdentifyRequest) string {
+	if req.Email != nil {
+		key, _, _ := s.emailMatchKey(*req.Email)
+		return matchCacheKey("email", key, req.AccountID)
+	}
+	return matchCacheKey("phone", *req.PhoneNumber, req.AccountID)
+}
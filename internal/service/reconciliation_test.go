@@ -0,0 +1,449 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/database"
+	"bitespeed/internal/models"
+)
+
+// testDBSeq names the in-memory database of each test service
+var testDBSeq atomic.Int64
+
+// newTestService returns a service on a fresh in-memory SQLite database with
+// the default configuration, adjusted by configure when it is not nil
+func newTestService(t *testing.T, configure func(*config.Config)) *ReconciliationService {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	dsn := fmt.Sprintf("file:service-test-%d?mode=memory&cache=shared", testDBSeq.Add(1))
+	db, err := database.New(dsn, database.Options{MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewReconciliationService(db, cfg)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	return s
+}
+
+// ptr returns a pointer to v
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// insertRow stores a contact as given, bypassing reconciliation, so tests can
+// set up graphs the service would never produce itself
+func insertRow(t *testing.T, s *ReconciliationService, id int64, email, phone *string, linkedID *int64, precedence string, createdAt time.Time) {
+	t.Helper()
+	query := `INSERT INTO contacts (id, phone_number, email, linked_id, link_precedence, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := s.conn.Exec(query, id, phone, email, linkedID, precedence, createdAt, createdAt); err != nil {
+		t.Fatalf("failed to insert contact %d: %v", id, err)
+	}
+}
+
+// countContacts returns the number of stored contacts, deleted ones included
+func countContacts(t *testing.T, s *ReconciliationService) int {
+	t.Helper()
+	var n int
+	if err := s.conn.QueryRow(`SELECT COUNT(*) FROM contacts`).Scan(&n); err != nil {
+		t.Fatalf("failed to count contacts: %v", err)
+	}
+	return n
+}
+
+// identify runs a request with default options, failing the test on errors
+func identify(t *testing.T, s *ReconciliationService, email, phone *string) *models.IdentifyResponse {
+	t.Helper()
+	response, err := s.Identify(context.Background(), models.IdentifyRequest{Email: email, PhoneNumber: phone}, IdentifyOptions{})
+	if err != nil {
+		t.Fatalf("identify(%v, %v) failed: %v", deref(email), deref(phone), err)
+	}
+	return response
+}
+
+// deref formats an optional value for messages
+func deref(value *string) string {
+	if value == nil {
+		return "<nil>"
+	}
+	return *value
+}
+
+// assertContact compares a response's contact with the expected cluster
+func assertContact(t *testing.T, got models.ContactResponse, primaryID int64, emails, phones []string, secondaryIDs []int64) {
+	t.Helper()
+	if got.PrimaryContactID != primaryID {
+		t.Errorf("primaryContactId = %d, want %d", got.PrimaryContactID, primaryID)
+	}
+	if !slices.Equal(got.Emails, emails) {
+		t.Errorf("emails = %v, want %v", got.Emails, emails)
+	}
+	if !slices.Equal(got.PhoneNumbers, phones) {
+		t.Errorf("phoneNumbers = %v, want %v", got.PhoneNumbers, phones)
+	}
+	if !slices.Equal(got.SecondaryContactIDs, secondaryIDs) {
+		t.Errorf("secondaryContactIds = %v, want %v", got.SecondaryContactIDs, secondaryIDs)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	type request struct {
+		email, phone *string
+	}
+	tests := []struct {
+		name     string
+		requests []request
+		// the last response is checked
+		primaryID    int64
+		emails       []string
+		phones       []string
+		secondaryIDs []int64
+		action       string
+		contacts     int
+	}{
+		{
+			name:      "new identifiers create a primary",
+			requests:  []request{{ptr("lorraine@hillvalley.edu"), ptr("123456")}},
+			primaryID: 1, emails: []string{"lorraine@hillvalley.edu"}, phones: []string{"123456"}, secondaryIDs: []int64{},
+			action: ActionCreatedPrimary, contacts: 1,
+		},
+		{
+			name: "a new email on a known phone creates a secondary",
+			requests: []request{
+				{ptr("lorraine@hillvalley.edu"), ptr("123456")},
+				{ptr("mcfly@hillvalley.edu"), ptr("123456")},
+			},
+			primaryID: 1, emails: []string{"lorraine@hillvalley.edu", "mcfly@hillvalley.edu"}, phones: []string{"123456"}, secondaryIDs: []int64{2},
+			action: ActionCreatedSecondary, contacts: 2,
+		},
+		{
+			name: "known identifiers change nothing",
+			requests: []request{
+				{ptr("lorraine@hillvalley.edu"), ptr("123456")},
+				{ptr("mcfly@hillvalley.edu"), ptr("123456")},
+				{ptr("mcfly@hillvalley.edu"), nil},
+			},
+			primaryID: 1, emails: []string{"lorraine@hillvalley.edu", "mcfly@hillvalley.edu"}, phones: []string{"123456"}, secondaryIDs: []int64{2},
+			action: ActionNoChange, contacts: 2,
+		},
+		{
+			name: "a request linking two primaries merges them into the older",
+			requests: []request{
+				{ptr("george@hillvalley.edu"), ptr("919191")},
+				{ptr("biffsucks@hillvalley.edu"), ptr("717171")},
+				{ptr("george@hillvalley.edu"), ptr("717171")},
+			},
+			primaryID: 1, emails: []string{"george@hillvalley.edu", "biffsucks@hillvalley.edu"}, phones: []string{"919191", "717171"}, secondaryIDs: []int64{2},
+			action: ActionMerged, contacts: 2,
+		},
+		{
+			name: "merging re-points the children of the demoted primary",
+			requests: []request{
+				{ptr("a@example.com"), ptr("111")},
+				{ptr("b@example.com"), ptr("222")},
+				{ptr("c@example.com"), ptr("222")},
+				{ptr("a@example.com"), ptr("222")},
+			},
+			primaryID: 1, emails: []string{"a@example.com", "b@example.com", "c@example.com"}, phones: []string{"111", "222"}, secondaryIDs: []int64{2, 3},
+			action: ActionMerged, contacts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, nil)
+			var response *models.IdentifyResponse
+			for _, req := range tt.requests {
+				response = identify(t, s, req.email, req.phone)
+			}
+
+			assertContact(t, response.Contact, tt.primaryID, tt.emails, tt.phones, tt.secondaryIDs)
+			if response.Outcome != tt.action {
+				t.Errorf("outcome = %q, want %q", response.Outcome, tt.action)
+			}
+			if got := countContacts(t, s); got != tt.contacts {
+				t.Errorf("stored %d contacts, want %d", got, tt.contacts)
+			}
+		})
+	}
+}
+
+func TestIdentifyRollsBackOnFailure(t *testing.T) {
+	s := newTestService(t, nil)
+	// Contact 3 hangs off secondary 2, so reconciling the cluster re-points it
+	// with updateContactPrecedence after the new secondary was inserted
+	now := time.Now()
+	insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("121"), nil, "primary", now.Add(-3*time.Hour))
+	insertRow(t, s, 2, ptr("emmett@hillvalley.edu"), nil, ptr(int64(1)), "secondary", now.Add(-2*time.Hour))
+	insertRow(t, s, 3, ptr("brown@hillvalley.edu"), nil, ptr(int64(2)), "secondary", now.Add(-time.Hour))
+
+	trigger := `CREATE TRIGGER fail_precedence_update BEFORE UPDATE OF link_precedence ON contacts
+				BEGIN SELECT RAISE(ABORT, 'injected failure'); END`
+	if _, err := s.conn.Exec(trigger); err != nil {
+		t.Fatalf("failed to install trigger: %v", err)
+	}
+
+	req := models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("1955")}
+	_, err := s.Identify(context.Background(), req, IdentifyOptions{})
+	if err == nil || !strings.Contains(err.Error(), "injected failure") {
+		t.Fatalf("identify error = %v, want the injected failure", err)
+	}
+	if got := countContacts(t, s); got != 3 {
+		t.Errorf("stored %d contacts after the rollback, want 3", got)
+	}
+	var linkedID int64
+	if err := s.conn.QueryRow(`SELECT linked_id FROM contacts WHERE id = 3`).Scan(&linkedID); err != nil {
+		t.Fatalf("failed to read contact 3: %v", err)
+	}
+	if linkedID != 2 {
+		t.Errorf("contact 3 is linked to %d after the rollback, want 2", linkedID)
+	}
+}
+
+func TestFlattenSecondaryChains(t *testing.T) {
+	tests := []struct {
+		name string
+		// linked_id of contacts 1 (the primary) to 4, 0 for none
+		links []int64
+		// linked_id expected afterwards
+		want []int64
+	}{
+		{name: "flat cluster", links: []int64{0, 1, 1, 1}, want: []int64{0, 1, 1, 1}},
+		{name: "chains at any depth", links: []int64{0, 1, 2, 3}, want: []int64{0, 1, 1, 1}},
+		{name: "self-linked primary", links: []int64{1, 1, 2, 1}, want: []int64{0, 1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, func(cfg *config.Config) { cfg.DBTimeout = 5 * time.Second })
+			s, cancel := s.withContext(context.Background())
+			defer cancel()
+
+			now := time.Now()
+			for i, link := range tt.links {
+				id, precedence := int64(i+1), "secondary"
+				var linkedID *int64
+				if link != 0 {
+					linkedID = ptr(link)
+				}
+				if id == 1 {
+					precedence = "primary"
+				}
+				insertRow(t, s, id, ptr(fmt.Sprintf("c%d@example.com", id)), nil, linkedID, precedence, now.Add(time.Duration(id)*time.Minute))
+			}
+
+			if err := s.flattenSecondaryChains(1); err != nil {
+				t.Fatalf("flattenSecondaryChains failed: %v", err)
+			}
+			for i, want := range tt.want {
+				var linkedID sql.NullInt64
+				if err := s.conn.QueryRow(`SELECT linked_id FROM contacts WHERE id = $1`, i+1).Scan(&linkedID); err != nil {
+					t.Fatalf("failed to read contact %d: %v", i+1, err)
+				}
+				if linkedID.Int64 != want {
+					t.Errorf("contact %d linked to %d, want %d", i+1, linkedID.Int64, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIdentifyRetriesWhenAnotherPrimaryAppears(t *testing.T) {
+	s := newTestService(t, func(cfg *config.Config) { cfg.VerifySinglePrimary = true })
+	s.SetIDGenerator(NewSequentialIDGenerator(100))
+
+	// Stand in for a concurrent writer: while the first attempt creates contact
+	// 100, a rival primary with the same email appears
+	trigger := `CREATE TRIGGER rival_primary AFTER INSERT ON contacts WHEN NEW.id = 100
+				BEGIN
+					INSERT INTO contacts (id, email, link_precedence, created_at, updated_at)
+					VALUES (1000, NEW.email, 'primary', NEW.created_at, NEW.updated_at);
+				END`
+	if _, err := s.conn.Exec(trigger); err != nil {
+		t.Fatalf("failed to install trigger: %v", err)
+	}
+
+	response := identify(t, s, ptr("marty@hillvalley.edu"), nil)
+	if response.Contact.PrimaryContactID != 101 {
+		t.Errorf("primaryContactId = %d, want 101 from the retried transaction", response.Contact.PrimaryContactID)
+	}
+	if got := countContacts(t, s); got != 1 {
+		t.Errorf("stored %d contacts, want the single primary of the retry", got)
+	}
+}
+
+func TestIdentifyGivesUpOnPersistentMultiplePrimaries(t *testing.T) {
+	s := newTestService(t, func(cfg *config.Config) {
+		cfg.VerifySinglePrimary = true
+		cfg.IdentifyMaxRetries = 2
+	})
+	trigger := `CREATE TRIGGER rival_primary AFTER INSERT ON contacts WHEN NEW.email = 'marty@hillvalley.edu'
+				BEGIN
+					INSERT INTO contacts (email, link_precedence, created_at, updated_at)
+					VALUES ('marty@hillvalley.edu', 'primary', NEW.created_at, NEW.updated_at);
+				END`
+	if _, err := s.conn.Exec(trigger); err != nil {
+		t.Fatalf("failed to install trigger: %v", err)
+	}
+
+	req := models.IdentifyRequest{Email: ptr("marty@hillvalley.edu")}
+	if _, err := s.Identify(context.Background(), req, IdentifyOptions{}); !errors.Is(err, errMultiplePrimaries) {
+		t.Fatalf("identify error = %v, want errMultiplePrimaries", err)
+	}
+	if got := countContacts(t, s); got != 0 {
+		t.Errorf("stored %d contacts, want every attempt rolled back", got)
+	}
+}
+
+// newFileTestService is newTestService on a SQLite file, for tests where WAL,
+// the busy timeout and concurrent writers matter
+func newFileTestService(t *testing.T) *ReconciliationService {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	db, err := database.New(filepath.Join(t.TempDir(), "contacts.db"), database.Options{})
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewReconciliationService(db, cfg)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	return s
+}
+
+func TestIdentifyConcurrentSQLite(t *testing.T) {
+	s := newFileTestService(t)
+
+	const requests = 50
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Every fifth request shares a phone number, so writers contend on the same clusters
+			req := models.IdentifyRequest{
+				Email:       ptr(fmt.Sprintf("user%d@hillvalley.edu", i)),
+				PhoneNumber: ptr(fmt.Sprintf("555%d", i%5)),
+			}
+			_, err := s.Identify(context.Background(), req, IdentifyOptions{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent identify failed: %v", err)
+		}
+	}
+	if got := countContacts(t, s); got != requests {
+		t.Errorf("%d contacts stored, want %d", got, requests)
+	}
+}
+
+func TestIdentifyConcurrentNewIdentifiers(t *testing.T) {
+	s := newFileTestService(t)
+	req := models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("1955")}
+
+	const requests = 2
+	primaryIDs := make(chan int64, requests)
+	var wg sync.WaitGroup
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := s.Identify(context.Background(), req, IdentifyOptions{})
+			if err != nil {
+				t.Errorf("identify failed: %v", err)
+				return
+			}
+			primaryIDs <- response.Contact.PrimaryContactID
+		}()
+	}
+	wg.Wait()
+	close(primaryIDs)
+
+	var ids []int64
+	for id := range primaryIDs {
+		ids = append(ids, id)
+	}
+	if len(ids) != requests || ids[0] != ids[1] {
+		t.Errorf("primaryContactIds = %v, want the same primary twice", ids)
+	}
+	var primaries int
+	if err := s.conn.QueryRow(`SELECT COUNT(*) FROM contacts WHERE link_precedence = 'primary'`).Scan(&primaries); err != nil {
+		t.Fatalf("failed to count primaries: %v", err)
+	}
+	if primaries != 1 {
+		t.Errorf("%d primaries stored, want 1", primaries)
+	}
+}
+
+func TestExactMatchFastPathAnswersLikeReconcile(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.IdentifyRequest
+	}{
+		{name: "email and phone", req: models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("111")}},
+		{name: "differently formatted", req: models.IdentifyRequest{Email: ptr(" Doc@HillValley.edu"), PhoneNumber: ptr("1-1-1")}},
+	}
+
+	created := time.Now().Add(-time.Hour)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var answers []string
+			for _, fastPath := range []bool{false, true} {
+				s := newTestService(t, func(cfg *config.Config) { cfg.ExactMatchFastPath = fastPath })
+				insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("111"), nil, "primary", created)
+				insertRow(t, s, 2, ptr("emmett@hillvalley.edu"), ptr("111"), ptr(int64(1)), "secondary", created)
+
+				response, err := s.Identify(context.Background(), tt.req, IdentifyOptions{Verbose: true})
+				if err != nil {
+					t.Fatalf("identify failed: %v", err)
+				}
+				if response.Resolution != ActionNoChange {
+					t.Errorf("fast path %v: resolution = %q, want %q", fastPath, response.Resolution, ActionNoChange)
+				}
+				if n := countContacts(t, s); n != 2 {
+					t.Errorf("fast path %v: %d contacts, want 2", fastPath, n)
+				}
+				answer, err := json.Marshal(response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				answers = append(answers, string(answer))
+			}
+			if answers[0] != answers[1] {
+				t.Errorf("fast path answered\n%s\nreconcile answered\n%s", answers[1], answers[0])
+			}
+		})
+	}
+}
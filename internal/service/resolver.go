@@ -0,0 +1,219 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// ConflictDecision is a conflict resolver's verdict on merging existing clusters
+type ConflictDecision string
+
+// Decisions a conflict resolver may return
+const (
+	// DecisionMerge links the clusters as usual
+	DecisionMerge ConflictDecision = "merge"
+	// DecisionKeepSeparate leaves every cluster unchanged and answers with the
+	// cluster the primary strategy would have kept
+	DecisionKeepSeparate ConflictDecision = "keep-separate"
+	// DecisionFlag holds the request in the review queue (HTTP 409 REVIEW_REQUIRED)
+	DecisionFlag ConflictDecision = "flag-for-review"
+)
+
+// ValidConflictDecision reports whether a decision is known
+func ValidConflictDecision(decision ConflictDecision) bool {
+	switch decision {
+	case DecisionMerge, DecisionKeepSeparate, DecisionFlag:
+		return true
+	}
+	return false
+}
+
+// ConflictResolver decides what happens when a request would merge two or more
+// existing clusters, letting deployments plug in their own business rules
+type ConflictResolver interface {
+	Resolve(ctx context.Context, conflict models.ClusterConflict) (ConflictDecision, error)
+}
+
+// ConflictResolverFunc adapts a Go function to ConflictResolver
+type ConflictResolverFunc func(ctx context.Context, conflict models.ClusterConflict) (ConflictDecision, error)
+
+// Resolve calls f
+func (f ConflictResolverFunc) Resolve(ctx context.Context, conflict models.ClusterConflict) (ConflictDecision, error) {
+	return f(ctx, conflict)
+}
+
+// HTTPConflictResolver POSTs the conflict as JSON to a resolver service, which
+// answers {"decision": "merge" | "keep-separate" | "flag-for-review"}
+type HTTPConflictResolver struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPConflictResolver creates a resolver calling url
+func NewHTTPConflictResolver(url string) *HTTPConflictResolver {
+	return &HTTPConflictResolver{URL: url, Client: http.DefaultClient}
+}
+
+// Resolve sends the conflict to the resolver service and returns its decision
+func (r *HTTPConflictResolver) Resolve(ctx context.Context, conflict models.ClusterConflict) (ConflictDecision, error) {
+	body, err := json.Marshal(conflict)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolver answered %s", resp.Status)
+	}
+	var verdict struct {
+		Decision ConflictDecision `json:"decision"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return "", fmt.Errorf("failed to decode resolver answer: %w", err)
+	}
+	return verdict.Decision, nil
+}
+
+// SetConflictResolver consults resolver whenever a request would merge existing
+// clusters. It gets timeout to answer; errors, timeouts and unknown decisions
+// apply fallback instead.
+func (s *ReconciliationService) SetConflictResolver(resolver ConflictResolver, timeout time.Duration, fallback ConflictDecision) {
+	s.resolver = resolver
+	s.resolverTimeout = timeout
+	s.resolverFallback = fallback
+}
+
+// clusterResolution is the resolver's decision about the clusters a request
+// would merge, taken before the identify transaction begins so the transaction
+// never waits on the resolver
+type clusterResolution struct {
+	decision   ConflictDecision
+	primaryIDs []int64
+}
+
+// errClustersChanged is returned when the clusters a request would merge differ
+// from those the resolver decided on; Identify asks again in a new attempt
+var errClustersChanged = errors.New("clusters changed since the conflict resolver was asked")
+
+// clusterPrimaryIDs returns the sorted ids of the primaries among the contacts
+func clusterPrimaryIDs(contacts []*models.Contact) []int64 {
+	var primaryIDs []int64
+	for _, c := range contacts {
+		if c.LinkPrecedence == "primary" {
+			primaryIDs = append(primaryIDs, c.ID)
+		}
+	}
+	slices.Sort(primaryIDs)
+	return slices.Compact(primaryIDs)
+}
+
+// resolveConflictAhead reads the clusters the request would merge and asks the
+// resolver about them; a single cluster is always merged. restored is the
+// deleted contact Restore brings back as a cluster of its own, if any.
+func (s *ReconciliationService) resolveConflictAhead(req models.IdentifyRequest, opts IdentifyOptions, restored *models.Contact) (*clusterResolution, error) {
+	var extra []*models.Contact
+	if restored != nil {
+		extra = append(extra, restored)
+	}
+	linkedContacts, err := s.matchCluster(req, opts, false, extra...)
+	if err != nil {
+		return nil, err
+	}
+
+	resolution := &clusterResolution{decision: DecisionMerge, primaryIDs: clusterPrimaryIDs(linkedContacts)}
+	if len(resolution.primaryIDs) < 2 {
+		return resolution, nil
+	}
+
+	conflict := models.ClusterConflict{Request: req}
+	for _, primaryID := range resolution.primaryIDs {
+		members, err := s.getAllLinkedContacts(primaryID)
+		if err != nil {
+			return nil, err
+		}
+		if restored != nil && primaryID == restored.ID {
+			members = append(members, restored)
+		}
+		conflict.Clusters = append(conflict.Clusters, models.ConflictCluster{PrimaryContactID: primaryID, Contacts: members})
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.resolverTimeout)
+	defer cancel()
+
+	decision, err := s.resolver.Resolve(ctx, conflict)
+	if err == nil && !ValidConflictDecision(decision) {
+		err = fmt.Errorf("unknown decision %q", decision)
+	}
+	if err != nil {
+		log.Printf("Conflict resolver failed for primaries %v, applying %s: %v", resolution.primaryIDs, s.resolverFallback, err)
+		decision = s.resolverFallback
+	}
+	resolution.decision = decision
+	return resolution, nil
+}
+
+// decisionFor returns the decision for the clusters of the loaded contacts,
+// which must be those the resolver was asked about
+func (r *clusterResolution) decisionFor(linkedContacts []*models.Contact) (ConflictDecision, []int64, error) {
+	primaryIDs := clusterPrimaryIDs(linkedContacts)
+	if len(primaryIDs) < 2 {
+		return DecisionMerge, primaryIDs, nil
+	}
+	if r == nil || !slices.Equal(r.primaryIDs, primaryIDs) {
+		return "", nil, errClustersChanged
+	}
+	return r.decision, primaryIDs, nil
+}
+
+// flagResolvedConflict stores a request the resolver flagged in the review queue
+// and returns the resulting conflict error
+func (s *ReconciliationService) flagResolvedConflict(req models.IdentifyRequest, primaryIDs []int64) error {
+	emailPrimaryID, phonePrimaryID := primaryIDs[0], primaryIDs[1]
+	if req.Email != nil && req.PhoneNumber != nil {
+		emailCluster, err := s.clusterOfFirstMatch(s.queryContactsByEmail(*req.Email, req.AccountID))
+		if err != nil {
+			return err
+		}
+		phoneCluster, err := s.clusterOfFirstMatch(s.queryContactsByPhoneNumber(*req.PhoneNumber, req.AccountID))
+		if err != nil {
+			return err
+		}
+		if emailCluster != nil && phoneCluster != nil && emailCluster.primaryID != phoneCluster.primaryID {
+			emailPrimaryID, phonePrimaryID = emailCluster.primaryID, phoneCluster.primaryID
+		}
+	}
+
+	reason := "conflict resolver flagged the merge of existing clusters"
+	reviewID, err := s.enqueueReview(req.Email, req.PhoneNumber, emailPrimaryID, phonePrimaryID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue review: %w", err)
+	}
+
+	log.Printf("Conflict resolver flagged identify request for review %d: primaries %v", reviewID, primaryIDs)
+	return &ConflictError{
+		Code:       ConflictReviewRequired,
+		Message:    reason,
+		PrimaryIDs: primaryIDs,
+		ReviewID:   reviewID,
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// seedRivalPrimaries stores two unrelated clusters that a request for
+// doc@hillvalley.edu with phone 222 would merge
+func seedRivalPrimaries(t *testing.T, s *ReconciliationService) {
+	t.Helper()
+	now := time.Now()
+	insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("111"), nil, "primary", now)
+	insertRow(t, s, 2, ptr("marty@hillvalley.edu"), ptr("222"), nil, "primary", now.Add(time.Minute))
+}
+
+// precedenceOf returns the link precedence stored for a contact
+func precedenceOf(t *testing.T, s *ReconciliationService, id int64) string {
+	t.Helper()
+	var precedence string
+	if err := s.conn.QueryRow(`SELECT link_precedence FROM contacts WHERE id = $1`, id).Scan(&precedence); err != nil {
+		t.Fatalf("failed to read contact %d: %v", id, err)
+	}
+	return precedence
+}
+
+func TestIdentifyAppliesResolverDecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision ConflictDecision
+		// precedence of contact 2 afterwards
+		want     string
+		conflict bool
+	}{
+		{name: "merge", decision: DecisionMerge, want: "secondary"},
+		{name: "keep separate", decision: DecisionKeepSeparate, want: "primary"},
+		{name: "flag for review", decision: DecisionFlag, want: "primary", conflict: true},
+		{name: "unknown decision falls back", decision: "shrug", want: "secondary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, nil)
+			seedRivalPrimaries(t, s)
+
+			var calls int
+			s.SetConflictResolver(ConflictResolverFunc(func(ctx context.Context, conflict models.ClusterConflict) (ConflictDecision, error) {
+				calls++
+				if len(conflict.Clusters) != 2 {
+					t.Errorf("resolver got %d clusters, want 2", len(conflict.Clusters))
+				}
+				// The only connection is busy while a transaction is open, so
+				// this read times out unless the resolver runs outside it
+				var count int
+				if err := s.db.Conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM contacts`).Scan(&count); err != nil {
+					t.Errorf("resolver could not read the database: %v", err)
+				}
+				return tt.decision, nil
+			}), time.Second, DecisionMerge)
+
+			response, err := s.Identify(context.Background(), models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("222")}, IdentifyOptions{})
+			var conflictErr *ConflictError
+			if tt.conflict != errors.As(err, &conflictErr) {
+				t.Fatalf("identify error = %v, want conflict: %v", err, tt.conflict)
+			}
+			if !tt.conflict {
+				if err != nil {
+					t.Fatalf("identify failed: %v", err)
+				}
+				if response.Contact.PrimaryContactID != 1 {
+					t.Errorf("primaryContactId = %d, want 1", response.Contact.PrimaryContactID)
+				}
+			}
+
+			if calls != 1 {
+				t.Errorf("resolver called %d times, want 1", calls)
+			}
+			if got := precedenceOf(t, s, 2); got != tt.want {
+				t.Errorf("contact 2 is %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentifyAsksResolverAgainWhenClustersChange(t *testing.T) {
+	s := newTestService(t, nil)
+	seedRivalPrimaries(t, s)
+
+	var calls int
+	s.SetConflictResolver(ConflictResolverFunc(func(ctx context.Context, conflict models.ClusterConflict) (ConflictDecision, error) {
+		calls++
+		if calls == 1 {
+			// A concurrent request adds a third cluster after the resolver was asked
+			insertRow(t, s, 3, ptr("biff@hillvalley.edu"), ptr("222"), nil, "primary", time.Now())
+		}
+		return DecisionKeepSeparate, nil
+	}), time.Second, DecisionMerge)
+
+	if _, err := s.Identify(context.Background(), models.IdentifyRequest{Email: ptr("doc@hillvalley.edu"), PhoneNumber: ptr("222")}, IdentifyOptions{}); err != nil {
+		t.Fatalf("identify failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("resolver called %d times, want 2", calls)
+	}
+	for _, id := range []int64{2, 3} {
+		if got := precedenceOf(t, s, id); got != "primary" {
+			t.Errorf("contact %d is %s, want primary", id, got)
+		}
+	}
+}
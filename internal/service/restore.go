@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// ErrContactNotDeleted is returned when restoring a contact that is still active
+var ErrContactNotDeleted = errors.New("contact is not deleted")
+
+// Restore clears a contact's deleted_at and reconciles it again, so it rejoins
+// whatever cluster now holds its email or phone number (including contacts
+// created after it was deleted) or becomes a primary of its own. It returns the
+// resulting identity; a conflict rolls the restore back.
+func (s *ReconciliationService) Restore(ctx context.Context, id int64) (*models.IdentifyResponse, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+
+	req, restored, err := s.loadDeletedContact(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		opts := IdentifyOptions{PrimaryStrategy: s.cfg.PrimaryStrategy, restoring: true}
+		// As in Identify, the resolver is asked before the transaction begins
+		if s.resolver != nil {
+			if opts.resolution, err = s.resolveConflictAhead(req, opts, restored); err != nil {
+				return nil, fmt.Errorf("failed to resolve cluster conflict: %w", err)
+			}
+		}
+
+		response, err := s.restoreTx(id, req, opts)
+		// Concurrent writers abort a restore the same way they abort Identify
+		if retryable(err) && attempt < s.cfg.IdentifyMaxRetries {
+			log.Printf("Restore of contact %d aborted by a concurrent request, retrying (attempt %d/%d): %v", id, attempt+1, s.cfg.IdentifyMaxRetries, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Restored contact %d into cluster of primary %d", id, response.Contact.PrimaryContactID)
+		return response, nil
+	}
+}
+
+// loadDeletedContact returns the identify request reconciling a deleted contact
+// and the contact as it will look once restored: a primary of its own
+func (s *ReconciliationService) loadDeletedContact(id int64) (models.IdentifyRequest, *models.Contact, error) {
+	var req models.IdentifyRequest
+	var email, phone, accountID sql.NullString
+	var createdAt time.Time
+	var deletedAt sql.NullTime
+	query := `SELECT email, phone_number, account_id, created_at, deleted_at FROM contacts WHERE id = $1`
+	err := s.conn.QueryRow(query, id).Scan(&email, &phone, &accountID, &createdAt, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return req, nil, ErrContactNotFound
+	}
+	if err != nil {
+		return req, nil, fmt.Errorf("failed to load contact %d: %w", id, err)
+	}
+	if !deletedAt.Valid {
+		return req, nil, ErrContactNotDeleted
+	}
+
+	if email.Valid {
+		req.Email = &email.String
+	}
+	if phone.Valid {
+		req.PhoneNumber = &phone.String
+	}
+	if accountID.Valid {
+		req.AccountID = &accountID.String
+	}
+	restored := &models.Contact{
+		ID:             id,
+		Email:          req.Email,
+		PhoneNumber:    req.PhoneNumber,
+		AccountID:      req.AccountID,
+		LinkPrecedence: "primary",
+		CreatedAt:      createdAt,
+		UpdatedAt:      time.Now(),
+	}
+	return req, restored, nil
+}
+
+// restoreTx restores and reconciles the contact in one transaction
+func (s *ReconciliationService) restoreTx(id int64, req models.IdentifyRequest, opts IdentifyOptions) (*models.IdentifyResponse, error) {
+	tx, err := s.db.Conn.BeginTx(s.ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	s = s.withConn(tx)
+
+	// Its old cluster may have moved on, so it comes back standalone and the
+	// reconciliation below links it to whatever now shares its identifiers
+	restore := `UPDATE contacts SET deleted_at = NULL, link_precedence = 'primary', linked_id = NULL, updated_at = $1 
+				WHERE id = $2 AND deleted_at IS NOT NULL`
+	result, err := s.conn.Exec(restore, time.Now(), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore contact %d: %w", id, err)
+	}
+	// A concurrent restore got there first
+	if restoredRows, err := result.RowsAffected(); err == nil && restoredRows == 0 {
+		return nil, ErrContactNotDeleted
+	}
+
+	response, _, err := s.identify(req, opts)
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		// The review entry is rolled back together with the restore
+		conflictErr.ReviewID = 0
+		return nil, conflictErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restore rolled back: %w", err)
+	}
+	// Its identifiers are back and primaries may have changed
+	s.stale.forgetAbsent(s.identifierCacheKeys(req)...)
+	s.stale.resetPositive()
+	if err := s.commit(tx); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return response, nil
+}
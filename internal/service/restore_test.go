@@ -0,0 +1,56 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"bitespeed/internal/config"
+)
+
+func TestRestoreRetriesWhenAnotherPrimaryAppears(t *testing.T) {
+	s := newTestService(t, func(cfg *config.Config) {
+		cfg.VerifySinglePrimary = true
+		cfg.IdentifyMaxRetries = 2
+	})
+	now := time.Now()
+	insertRow(t, s, 1, ptr("doc@hillvalley.edu"), ptr("222"), nil, "primary", now)
+	insertRow(t, s, 2, ptr("marty@hillvalley.edu"), ptr("222"), nil, "primary", now.Add(time.Second))
+	if _, err := s.conn.Exec(`UPDATE contacts SET deleted_at = $1 WHERE id = 2`, now); err != nil {
+		t.Fatalf("failed to delete contact 2: %v", err)
+	}
+
+	// Stand in for a concurrent writer: whenever the restored contact joins
+	// primary 1, a rival primary with its email appears
+	trigger := `CREATE TRIGGER rival_primary AFTER UPDATE OF link_precedence ON contacts WHEN NEW.id = 2 AND NEW.link_precedence = 'secondary'
+				BEGIN
+					INSERT INTO contacts (email, link_precedence, created_at, updated_at)
+					VALUES (NEW.email, 'primary', NEW.created_at, NEW.updated_at);
+				END`
+	if _, err := s.conn.Exec(trigger); err != nil {
+		t.Fatalf("failed to install trigger: %v", err)
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	if _, err := s.Restore(context.Background(), 2); !errors.Is(err, errMultiplePrimaries) {
+		t.Fatalf("restore error = %v, want errMultiplePrimaries", err)
+	}
+
+	if retries := strings.Count(logs.String(), "retrying"); retries != 2 {
+		t.Errorf("retried %d times, want 2", retries)
+	}
+	if got := countContacts(t, s); got != 2 {
+		t.Errorf("stored %d contacts, want every attempt rolled back", got)
+	}
+	var deleted bool
+	if err := s.conn.QueryRow(`SELECT deleted_at IS NOT NULL FROM contacts WHERE id = 2`).Scan(&deleted); err != nil || !deleted {
+		t.Errorf("contact 2 deleted = %v (%v), want the restore rolled back", deleted, err)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// checkClusterConflict flags requests whose email and phone belong to two different
+// established clusters (more than one member each) sharing no identifiers, which
+// usually means two people used the same device rather than one person
+func (s *ReconciliationService) checkClusterConflict(req models.IdentifyRequest) error {
+	conflict, err := s.clusterConflict(req)
+	if err != nil || conflict == nil {
+		return err
+	}
+
+	conflict.ReviewID, err = s.enqueueReview(req.Email, req.PhoneNumber, conflict.PrimaryIDs[0], conflict.PrimaryIDs[1], conflict.Message)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue review: %w", err)
+	}
+
+	log.Printf("Flagged identify request for review %d: primaries %d and %d", conflict.ReviewID, conflict.PrimaryIDs[0], conflict.PrimaryIDs[1])
+	return conflict
+}
+
+// clusterConflict returns the conflict checkClusterConflict flags, without a
+// review queue entry, or nil when the request may merge
+func (s *ReconciliationService) clusterConflict(req models.IdentifyRequest) (*ConflictError, error) {
+	email, phoneNumber := req.Email, req.PhoneNumber
+	if email == nil || *email == "" || phoneNumber == nil || *phoneNumber == "" {
+		return nil, nil
+	}
+
+	emailCluster, err := s.clusterOfFirstMatch(s.queryContactsByEmail(*email, req.AccountID))
+	if err != nil || emailCluster == nil {
+		return nil, err
+	}
+	phoneCluster, err := s.clusterOfFirstMatch(s.queryContactsByPhoneNumber(*phoneNumber, req.AccountID))
+	if err != nil || phoneCluster == nil {
+		return nil, err
+	}
+
+	if emailCluster.primaryID == phoneCluster.primaryID {
+		return nil, nil
+	}
+	if len(emailCluster.members) < 2 || len(phoneCluster.members) < 2 {
+		return nil, nil
+	}
+	if clustersShareIdentifier(emailCluster.members, phoneCluster.members) {
+		return nil, nil
+	}
+
+	return &ConflictError{
+		Code:       ConflictReviewRequired,
+		Message:    "email and phone number belong to two established clusters with no shared identifiers",
+		PrimaryIDs: []int64{emailCluster.primaryID, phoneCluster.primaryID},
+	}, nil
+}
+
+// contactCluster is a primary together with all of its active members
+type contactCluster struct {
+	primaryID int64
+	members   []*models.Contact
+}
+
+// clusterOfFirstMatch loads the cluster of the first matched contact, or nil if nothing matched
+func (s *ReconciliationService) clusterOfFirstMatch(matches []*models.Contact, err error) (*contactCluster, error) {
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+
+	primaryID, err := s.resolvePrimaryID(matches[0].ID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.getAllLinkedContacts(primaryID)
+	if err != nil {
+		return nil, err
+	}
+	return &contactCluster{primaryID: primaryID, members: members}, nil
+}
+
+// clustersShareIdentifier reports whether any email or phone number appears in both clusters
+func clustersShareIdentifier(a, b []*models.Contact) bool {
+	identifiers := make(map[string]bool)
+	for _, c := range a {
+		if c.Email != nil && *c.Email != "" {
+			identifiers["email:"+*c.Email] = true
+		}
+		if c.PhoneNumber != nil && *c.PhoneNumber != "" {
+			identifiers["phone:"+*c.PhoneNumber] = true
+		}
+	}
+
+	for _, c := range b {
+		if c.Email != nil && identifiers["email:"+*c.Email] {
+			return true
+		}
+		if c.PhoneNumber != nil && identifiers["phone:"+*c.PhoneNumber] {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueReview stores a flagged request in the review queue
+func (s *ReconciliationService) enqueueReview(email, phoneNumber *string, emailPrimaryID, phonePrimaryID int64, reason string) (int64, error) {
+	query := `INSERT INTO review_queue (email, phone_number, email_primary_id, phone_primary_id, reason, status, created_at) 
+			  VALUES ($1, $2, $3, $4, $5, 'pending', $6) RETURNING id`
+
+	var id int64
+	err := s.conn.QueryRow(query, email, phoneNumber, emailPrimaryID, phonePrimaryID, reason, time.Now()).Scan(&id)
+	return id, err
+}
+
+// ListPendingReviews returns the review queue entries still awaiting a decision
+func (s *ReconciliationService) ListPendingReviews(ctx context.Context) ([]models.ReviewItem, error) {
+	s, cancel := s.withContext(ctx)
+	defer cancel()
+	query := `SELECT id, email, phone_number, email_primary_id, phone_primary_id, reason, status, created_at 
+			  FROM review_queue WHERE status = 'pending' ORDER BY id`
+
+	rows, err := s.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ReviewItem{}
+	for rows.Next() {
+		var item models.ReviewItem
+		err := rows.Scan(&item.ID, &item.Email, &item.PhoneNumber, &item.EmailPrimaryID, &item.PhonePrimaryID, &item.Reason, &item.Status, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
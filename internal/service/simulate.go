@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"bitespeed/internal/config"
+	"bitespeed/internal/database"
+	"bitespeed/internal/models"
+)
+
+// MaxSimulationSteps caps the identify requests accepted by Simulate
+const MaxSimulationSteps = 500
+
+// simulationSeq names the in-memory database of each simulation
+var simulationSeq atomic.Int64
+
+// Simulate replays identify requests against a throwaway in-memory SQLite
+// database configured like the live service and returns every step's outcome
+// together with the final clusters. The real data is never touched.
+func Simulate(ctx context.Context, cfg *config.Config, requests []models.IdentifyRequest) (*models.SimulationResult, error) {
+	if len(requests) > MaxSimulationSteps {
+		return nil, fmt.Errorf("at most %d requests may be simulated", MaxSimulationSteps)
+	}
+
+	dsn := fmt.Sprintf("file:simulation-%d?mode=memory&cache=shared", simulationSeq.Add(1))
+	db, err := database.New(dsn, database.Options{MaxIdleConns: 2})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create simulation database: %w", err)
+	}
+	defer db.Close()
+
+	sim, err := NewReconciliationService(db, cfg)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.SimulationResult{Steps: []models.SimulationStep{}}
+	for _, req := range requests {
+		step := models.SimulationStep{Request: req}
+		if normalized := normalizeRequest(req); normalized.Email == nil && normalized.PhoneNumber == nil {
+			step.Error = "either email or phoneNumber must be provided"
+			result.Steps = append(result.Steps, step)
+			continue
+		}
+
+		response, err := sim.Identify(ctx, req, IdentifyOptions{})
+		if err != nil {
+			step.Error = err.Error()
+		} else {
+			step.Response = response
+		}
+		result.Steps = append(result.Steps, step)
+	}
+
+	if result.Clusters, err = sim.allClusters(); err != nil {
+		return nil, fmt.Errorf("failed to collect simulated clusters: %w", err)
+	}
+	return result, nil
+}
+
+// allClusters returns the consolidated view of every active primary, by id
+func (s *ReconciliationService) allClusters() ([]models.ContactResponse, error) {
+	query := `SELECT id, phone_number, email, linked_id, link_precedence, created_at, updated_at, deleted_at 
+			  FROM contacts WHERE link_precedence = 'primary' AND deleted_at IS NULL ORDER BY id`
+	primaries, err := s.queryContacts(query)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := []models.ContactResponse{}
+	for _, primary := range primaries {
+		response, err := s.buildResponse(primary.ID, IdentifyOptions{})
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, response.Contact)
+	}
+	return clusters, nil
+}
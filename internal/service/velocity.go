@@ -0,0 +1,120 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// velocityTracker counts, per identifier, the distinct partner identifiers it
+// arrived with during a sliding window. An identifier seen with more partners
+// than the limit (a shared family phone, a scripted signup) is blocked from
+// linking until the window slides past enough of them. A zero limit disables it.
+type velocityTracker struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	partners  map[string]map[string]time.Time
+	blocked   map[string]bool
+	lastSweep time.Time
+}
+
+// newVelocityTracker creates a tracker allowing limit partners per window
+func newVelocityTracker(limit int, window time.Duration) *velocityTracker {
+	return &velocityTracker{
+		limit:    limit,
+		window:   window,
+		partners: make(map[string]map[string]time.Time),
+		blocked:  make(map[string]bool),
+	}
+}
+
+// record notes that key arrived together with partner and reports whether key
+// is now over the limit
+func (t *velocityTracker) record(key, partner string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.sweep(now)
+
+	seen := t.partners[key]
+	if seen == nil {
+		seen = make(map[string]time.Time)
+		t.partners[key] = seen
+	}
+	seen[partner] = now
+	return t.over(key, now)
+}
+
+// over reports whether key exceeds the limit, logging when it starts or stops
+// being blocked; the caller holds mu
+func (t *velocityTracker) over(key string, now time.Time) bool {
+	seen := t.partners[key]
+	for partner, at := range seen {
+		if now.Sub(at) > t.window {
+			delete(seen, partner)
+		}
+	}
+	if len(seen) == 0 {
+		delete(t.partners, key)
+	}
+
+	over := len(seen) > t.limit
+	if over != t.blocked[key] {
+		if over {
+			t.blocked[key] = true
+			log.Printf("Identifier joined %d distinct contacts within %s, no longer auto-linking it", len(seen), t.window)
+		} else {
+			delete(t.blocked, key)
+		}
+	}
+	return over
+}
+
+// sweep drops expired partners of every identifier once per window; the caller holds mu
+func (t *velocityTracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.window {
+		return
+	}
+	t.lastSweep = now
+	for key := range t.partners {
+		t.over(key, now)
+	}
+}
+
+// isBlocked reports whether key is currently over the limit
+func (t *velocityTracker) isBlocked(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.blocked[key] {
+		return false
+	}
+	return t.over(key, time.Now())
+}
+
+// linkBlocked records the request's identifiers as partners of each other and
+// reports whether its email and phone number are too busy to link on (treated
+// as shared identifiers)
+func (s *ReconciliationService) linkBlocked(req models.IdentifyRequest) (emailBlocked, phoneBlocked bool) {
+	if s.velocity.limit <= 0 {
+		return false, false
+	}
+
+	var emailKey, phoneKey string
+	if req.Email != nil && *req.Email != "" {
+		emailKey = matchCacheKey("email", *req.Email, req.AccountID)
+	}
+	if req.PhoneNumber != nil && *req.PhoneNumber != "" {
+		phoneKey = matchCacheKey("phone", *req.PhoneNumber, req.AccountID)
+	}
+
+	// Dry runs check the current state without recording the pair
+	if emailKey != "" && phoneKey != "" && !s.dryRun {
+		return s.velocity.record(emailKey, phoneKey), s.velocity.record(phoneKey, emailKey)
+	}
+	return emailKey != "" && s.velocity.isBlocked(emailKey), phoneKey != "" && s.velocity.isBlocked(phoneKey)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"bitespeed/internal/models"
+)
+
+// Identifier kinds stored in verified_identifiers
+const (
+	identifierEmail = "email"
+	identifierPhone = "phone"
+)
+
+// recordVerification stores the identifiers the request marks as verified
+func (s *ReconciliationService) recordVerification(req models.IdentifyRequest) error {
+	query := `INSERT INTO verified_identifiers (kind, value, account_id, verified_at) VALUES ($1, $2, $3, $4) 
+			  ON CONFLICT (kind, value, account_id) DO NOTHING`
+
+	if req.EmailVerified && req.Email != nil {
+		if _, err := s.conn.Exec(query, identifierEmail, *req.Email, accountKey(req.AccountID), time.Now()); err != nil {
+			return err
+		}
+	}
+	if req.PhoneNumberVerified && req.PhoneNumber != nil {
+		if _, err := s.conn.Exec(query, identifierPhone, *req.PhoneNumber, accountKey(req.AccountID), time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasVerifiedIdentifier reports whether the contact's email or phone number is verified
+func (s *ReconciliationService) hasVerifiedIdentifier(c *models.Contact, accountID *string) (bool, error) {
+	query := `SELECT COUNT(*) FROM verified_identifiers 
+			  WHERE account_id = $1 AND ((kind = 'email' AND value = $2) OR (kind = 'phone' AND value = $3))`
+
+	var count int
+	err := s.conn.QueryRow(query, accountKey(accountID), c.Email, c.PhoneNumber).Scan(&count)
+	return count > 0, err
+}
+
+// clusterVerification returns the verified identifiers of a primary's active
+// cluster, keyed as "email:<value>" and "phone:<value>"
+func (s *ReconciliationService) clusterVerification(primaryID int64) (map[string]bool, error) {
+	query := `SELECT DISTINCT v.kind, v.value FROM verified_identifiers v 
+			  JOIN contacts c ON COALESCE(c.account_id, '') = v.account_id 
+			   AND ((v.kind = 'email' AND c.email = v.value) OR (v.kind = 'phone' AND c.phone_number = v.value)) 
+			  WHERE (c.id = $1 OR c.linked_id = $2) AND c.deleted_at IS NULL`
+
+	rows, err := s.conn.Query(query, primaryID, primaryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	verified := make(map[string]bool)
+	for rows.Next() {
+		var kind, value string
+		if err := rows.Scan(&kind, &value); err != nil {
+			return nil, err
+		}
+		verified[kind+":"+value] = true
+	}
+	return verified, rows.Err()
+}
+
+// identifierVerification returns which identifiers of the contacts are
+// verified, keyed like clusterVerification. The request's own verified flags
+// count as already recorded, so a dry run projects them as identify stores them.
+func (s *ReconciliationService) identifierVerification(contacts []*models.Contact, req models.IdentifyRequest) (map[string]bool, error) {
+	verified := make(map[string]bool)
+	if req.EmailVerified && req.Email != nil {
+		verified[identifierEmail+":"+*req.Email] = true
+	}
+	if req.PhoneNumberVerified && req.PhoneNumber != nil {
+		verified[identifierPhone+":"+*req.PhoneNumber] = true
+	}
+
+	query := `SELECT COUNT(*) FROM verified_identifiers WHERE kind = $1 AND value = $2 AND account_id = $3`
+	for _, c := range contacts {
+		for kind, value := range map[string]*string{identifierEmail: c.Email, identifierPhone: c.PhoneNumber} {
+			if value == nil {
+				continue
+			}
+			key := kind + ":" + *value
+			if _, checked := verified[key]; checked {
+				continue
+			}
+			var count int
+			if err := s.conn.QueryRow(query, kind, *value, accountKey(req.AccountID)).Scan(&count); err != nil {
+				return nil, err
+			}
+			verified[key] = count > 0
+		}
+	}
+	return verified, nil
+}
+
+// orderVerifiedFirst moves verified values to the front, keeping the relative
+// order within verified and unverified values, and returns the verified ones
+func orderVerifiedFirst(values []string, kind string, verified map[string]bool) []string {
+	sort.SliceStable(values, func(i, j int) bool {
+		return verified[kind+":"+values[i]] && !verified[kind+":"+values[j]]
+	})
+
+	var verifiedValues []string
+	for _, value := range values {
+		if verified[kind+":"+value] {
+			verifiedValues = append(verifiedValues, value)
+		}
+	}
+	return verifiedValues
+}
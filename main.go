@@ -1,53 +1,251 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"bitespeed/internal/config"
 	"bitespeed/internal/database"
+	"bitespeed/internal/decisionlog"
 	"bitespeed/internal/handlers"
+	"bitespeed/internal/metrics"
+	"bitespeed/internal/models"
+	"bitespeed/internal/openapi"
+	"bitespeed/internal/requestid"
+	"bitespeed/internal/service"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
-	// Get port from environment or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Load configuration from environment
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		log.Fatal(err)
 	}
+	log.Println("Shutdown complete")
+}
 
-	// Get database path from environment or default to local file
-	dbPath := os.Getenv("DATABASE_URL")
-	if dbPath == "" {
-		dbPath = "./bitespeed.db"
+// run serves the API until ctx is cancelled, then shuts down gracefully: it
+// stops accepting requests and drains in-flight ones, flushes the audit queue
+// and closes the database last, all within cfg.ShutdownTimeout
+func run(ctx context.Context, cfg *config.Config) error {
+	if !handlers.ValidLogFormat(cfg.LogFormat) {
+		return fmt.Errorf("invalid LOG_FORMAT %q", cfg.LogFormat)
 	}
 
 	// Initialize database
-	db, err := database.New(dbPath)
+	db, err := database.New(cfg.DatabaseURL, database.Options{
+		MaxOpenConns:      cfg.DBMaxOpenConns,
+		MaxIdleConns:      cfg.DBMaxIdleConns,
+		WarmUp:            cfg.DBWarmUp,
+		ConnMaxLifetime:   cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime:   cfg.DBConnMaxIdleTime,
+		KeepAliveInterval: cfg.DBKeepAliveInterval,
+	})
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
-	defer db.Close()
+	defer func() {
+		log.Println("Closing database")
+		db.Close()
+	}()
 
-	// Create handler
-	identifyHandler := handlers.NewIdentifyHandler(db)
+	svc, err := service.NewReconciliationService(db, cfg)
+	if err != nil {
+		return err
+	}
+	// Contacts stored before the normalization steps changed keep their old form
+	if err := svc.BackfillNormalizedIdentifiers(ctx); err != nil {
+		return fmt.Errorf("failed to renormalize stored identifiers: %w", err)
+	}
+	// Contacts stored before their provider joined EMAIL_CANONICAL_PROVIDERS lack a match key
+	if err := svc.BackfillEmailMatchKeys(ctx); err != nil {
+		return fmt.Errorf("failed to backfill email match keys: %w", err)
+	}
 
-	// Setup router
-	router := mux.NewRouter()
-	router.HandleFunc("/identify", identifyHandler.Handle).Methods("POST")
+	// Integrations still reading the misspelled key get it until the next release
+	models.LegacyPrimaryKey = cfg.LegacyPrimaryKey
 
-	// Health check endpoint
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
-	}).Methods("GET")
+	// Decision events for model training, written as JSON lines
+	if cfg.DecisionLog {
+		sink := os.Stdout
+		if cfg.DecisionLogPath != "" {
+			f, err := os.OpenFile(cfg.DecisionLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return fmt.Errorf("failed to open decision log: %w", err)
+			}
+			defer f.Close()
+			sink = f
+		}
+		svc.SetDecisionLogger(decisionlog.New(sink, cfg.DecisionLogSalt))
+	}
+
+	// Business rules deciding on merges of existing clusters, served by an external resolver
+	if cfg.ConflictResolverURL != "" {
+		fallback := service.ConflictDecision(cfg.ConflictResolverFallback)
+		if !service.ValidConflictDecision(fallback) {
+			return fmt.Errorf("invalid CONFLICT_RESOLVER_FALLBACK %q", cfg.ConflictResolverFallback)
+		}
+		resolver := service.NewHTTPConflictResolver(cfg.ConflictResolverURL)
+		svc.SetConflictResolver(resolver, cfg.ConflictResolverTimeout, fallback)
+	}
+
+	// Raw identify payloads for auditing, written in the background
+	if cfg.AuditLog {
+		svc.StartAuditLog()
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		return err
+	}
+	router := newRouter(db, svc, spec, cfg)
+	// Request ids and the access log cover every request, including unmatched routes
+	handler := requestid.Middleware(handlers.AccessLog(cfg.LogFormat)(router))
 
 	// Start server
-	addr := ":" + port
-	log.Printf("Server starting on %s", addr)
-	if err := http.ListenAndServe(addr, router); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: handler}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on %s", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down (timeout %s)", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	log.Println("Draining in-flight requests")
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to drain requests: %w", err)
+	}
+	log.Println("Flushing audit log")
+	if err := svc.FlushAuditLog(shutdownCtx); err != nil {
+		log.Printf("Error flushing audit log: %v", err)
 	}
+	return nil
+}
+
+// newRouter registers the routes of every enabled feature group
+func newRouter(db *database.DB, svc *service.ReconciliationService, spec *openapi.Spec, cfg *config.Config) *mux.Router {
+	router := mux.NewRouter()
+
+	// Browser pages on CORS_ORIGINS may call /identify and /health
+	var cors *handlers.CORS
+	if len(cfg.CORSOrigins) > 0 {
+		cors = handlers.NewCORS(cfg.CORSOrigins)
+	}
+
+	router.NotFoundHandler = http.HandlerFunc(handlers.NotFound)
+	router.MethodNotAllowedHandler = handlers.MethodNotAllowed(router)
+
+	// Status echoed in the body for clients that cannot read the status line
+	if cfg.EchoStatus {
+		router.Use(handlers.EchoStatus)
+		router.NotFoundHandler = handlers.EchoStatus(router.NotFoundHandler)
+		router.MethodNotAllowedHandler = handlers.EchoStatus(router.MethodNotAllowedHandler)
+	}
+
+	if cfg.FeatureEnabled("identify") {
+		identifyHandler := handlers.NewIdentifyHandler(svc, cfg.MaxBodyBytes, spec)
+		handle, bulkHandle := identifyHandler.Handle, identifyHandler.BulkHandle
+		if cfg.IdentifyConcurrency > 0 {
+			queue := handlers.NewFairQueue(cfg.IdentifyConcurrency, cfg.IdentifyQueueSize, cfg.IdentifyQueueTimeout)
+			handle, bulkHandle = queue.Wrap(handle), queue.Wrap(bulkHandle)
+		}
+		// Requests the spec rejects never take a queue slot
+		handle = handlers.NewRequestValidator(spec, cfg.MaxBodyBytes).Wrap(handle)
+		// Unauthenticated requests are turned away before anything but the rate limit
+		requireToken := handlers.RequireToken(cfg.APIToken)
+		handle, bulkHandle = requireToken(handle), requireToken(bulkHandle)
+		if cfg.RateLimitRPS > 0 {
+			limiter := handlers.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst, cfg.RateLimitTrustProxy)
+			handle, bulkHandle = limiter.Wrap(handle), limiter.Wrap(bulkHandle)
+		}
+		if cors != nil {
+			handle = cors.Wrap(handle)
+			router.HandleFunc("/identify", cors.Preflight).Methods("OPTIONS")
+		}
+		router.HandleFunc("/identify", handle).Methods("POST")
+		router.HandleFunc("/bulk-identify", bulkHandle).Methods("POST")
+	}
+
+	if cfg.FeatureEnabled("lookup") {
+		primaryHandler := handlers.NewPrimaryHandler(svc)
+		router.HandleFunc("/primary", primaryHandler.Handle).Methods("GET")
+	}
+
+	if cfg.FeatureEnabled("contacts") {
+		contactsHandler := handlers.NewContactsHandler(svc)
+		router.HandleFunc("/contacts/{id}", contactsHandler.Get).Methods("GET")
+		router.HandleFunc("/contacts/{id}/lineage", contactsHandler.Lineage).Methods("GET")
+		router.HandleFunc("/contacts/{id}/bridges", contactsHandler.Bridges).Methods("GET")
+		router.HandleFunc("/contacts/{id}/diagnostics", contactsHandler.Diagnostics).Methods("GET")
+		router.HandleFunc("/cluster/{id}", contactsHandler.Cluster).Methods("GET")
+		router.HandleFunc("/crm/{id}", contactsHandler.CRM).Methods("GET")
+	}
+
+	// Admin endpoints (require ADMIN_TOKEN)
+	if cfg.FeatureEnabled("admin") {
+		adminHandler := handlers.NewAdminHandler(db, svc, cfg.AdminToken)
+		router.HandleFunc("/admin/maintenance", adminHandler.RequireAdmin(adminHandler.Maintenance)).Methods("POST")
+		router.HandleFunc("/admin/review-queue", adminHandler.RequireAdmin(adminHandler.ReviewQueue)).Methods("GET")
+		router.HandleFunc("/contacts/delete", adminHandler.RequireAdmin(adminHandler.DeleteContacts)).Methods("POST")
+		router.HandleFunc("/contacts/{id}", adminHandler.RequireAdmin(adminHandler.DeleteContact)).Methods("DELETE")
+		router.HandleFunc("/contacts/{id}/restore", adminHandler.RequireAdmin(adminHandler.RestoreContact)).Methods("POST")
+		router.HandleFunc("/admin/audit", adminHandler.RequireAdmin(adminHandler.AuditLog)).Methods("GET")
+		router.HandleFunc("/admin/stats", adminHandler.RequireAdmin(adminHandler.Stats)).Methods("GET")
+		router.HandleFunc("/export.csv", adminHandler.RequireAdmin(adminHandler.ExportCSV)).Methods("GET")
+		router.HandleFunc("/admin/aliases", adminHandler.RequireAdmin(adminHandler.ListAliases)).Methods("GET")
+		router.HandleFunc("/admin/aliases", adminHandler.RequireAdmin(adminHandler.SetAlias)).Methods("PUT")
+		router.HandleFunc("/admin/aliases/{alias}", adminHandler.RequireAdmin(adminHandler.DeleteAlias)).Methods("DELETE")
+	}
+
+	// Replays identify requests on a throwaway database, never in production
+	if cfg.DevProfile() && cfg.FeatureEnabled("simulate") {
+		simulateHandler := handlers.NewSimulateHandler(cfg)
+		router.HandleFunc("/simulate", simulateHandler.Handle).Methods("POST")
+	}
+
+	if cfg.FeatureEnabled("metrics") {
+		router.Use(metrics.Middleware)
+		metrics.RegisterContactCount(func() (int64, error) {
+			return svc.CountContacts(context.Background())
+		})
+		router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	}
+
+	// Kubernetes probes; /health predates them and stays a readiness alias
+	if cfg.FeatureEnabled("health") {
+		healthHandler := handlers.NewHealthHandler(db)
+		router.HandleFunc("/livez", healthHandler.Live).Methods("GET")
+		router.HandleFunc("/readyz", healthHandler.Ready).Methods("GET")
+		health := healthHandler.Ready
+		if cors != nil {
+			health = cors.Wrap(health)
+			router.HandleFunc("/health", cors.Preflight).Methods("OPTIONS")
+		}
+		router.HandleFunc("/health", health).Methods("GET")
+	}
+
+	return router
 }
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS merged_into (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    old_primary_id INTEGER NOT NULL,
+    new_primary_id INTEGER NOT NULL,
+    merged_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_merged_into_new ON merged_into(new_primary_id);
+CREATE INDEX IF NOT EXISTS idx_merged_into_old ON merged_into(old_primary_id);
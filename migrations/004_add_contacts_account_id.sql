@@ -0,0 +1,3 @@
+ALTER TABLE contacts ADD COLUMN account_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_account_id ON contacts(account_id);
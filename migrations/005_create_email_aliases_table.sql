@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS email_aliases (
+    alias TEXT PRIMARY KEY,
+    canonical TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
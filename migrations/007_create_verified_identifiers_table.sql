@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS verified_identifiers (
+    kind TEXT NOT NULL CHECK(kind IN ('email', 'phone')),
+    value TEXT NOT NULL,
+    account_id TEXT NOT NULL DEFAULT '',
+    verified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (kind, value, account_id)
+);
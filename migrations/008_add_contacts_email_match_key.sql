@@ -0,0 +1,3 @@
+ALTER TABLE contacts ADD COLUMN email_match_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_email_match_key ON contacts(email_match_key);